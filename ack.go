@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// ackTimeout is how long clients have to acknowledge a message before
+	// they are reported as suspect.
+	ackTimeout = 2 * time.Second
+
+	// maxTrackedDeliveries bounds how many messages are tracked at once;
+	// the oldest is dropped unreported when more are published.
+	maxTrackedDeliveries = 8
+)
+
+// delivery tracks which clients acknowledged one message.
+type delivery struct {
+	expected []string
+	acked    map[string]bool
+}
+
+// deliveryLog tracks acknowledgements of recent messages when delivery
+// confirmation is enabled with -confirm. Nothing is tracked otherwise.
+type deliveryLog struct {
+	mu      sync.Mutex
+	clients map[*websocket.Conn]bool
+	pending map[Version]*delivery
+	order   []Version
+}
+
+var deliveries = &deliveryLog{
+	clients: map[*websocket.Conn]bool{},
+	pending: map[Version]*delivery{},
+}
+
+func (l *deliveryLog) connect(conn *websocket.Conn) {
+	l.mu.Lock()
+	l.clients[conn] = true
+	l.mu.Unlock()
+}
+
+func (l *deliveryLog) disconnect(conn *websocket.Conn) {
+	l.mu.Lock()
+	delete(l.clients, conn)
+	l.mu.Unlock()
+}
+
+// track starts waiting for every connected client to acknowledge version,
+// and reports the outcome after ackTimeout.
+func (l *deliveryLog) track(version Version) {
+	d := &delivery{acked: map[string]bool{}}
+
+	l.mu.Lock()
+	for conn := range l.clients {
+		d.expected = append(d.expected, conn.RemoteAddr().String())
+	}
+	sort.Strings(d.expected)
+	l.pending[version] = d
+	l.order = append(l.order, version)
+	if len(l.order) > maxTrackedDeliveries {
+		delete(l.pending, l.order[0])
+		l.order = l.order[1:]
+	}
+	l.mu.Unlock()
+
+	time.AfterFunc(ackTimeout, func() { l.report(version) })
+}
+
+func (l *deliveryLog) ack(version Version, client string) {
+	l.mu.Lock()
+	if d, ok := l.pending[version]; ok {
+		d.acked[client] = true
+	}
+	l.mu.Unlock()
+}
+
+// report prints which clients acknowledged version and which didn't with
+// -verbose, then stops tracking it.
+func (l *deliveryLog) report(version Version) {
+	l.mu.Lock()
+	d, ok := l.pending[version]
+	delete(l.pending, version)
+	for i, v := range l.order {
+		if v == version {
+			l.order = append(l.order[:i], l.order[i+1:]...)
+			break
+		}
+	}
+	l.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	var acked, suspect []string
+	for _, client := range d.expected {
+		if d.acked[client] {
+			acked = append(acked, client)
+		} else {
+			suspect = append(suspect, client)
+		}
+	}
+	line := fmt.Sprintf("Message %d: acknowledged by %v", version, acked)
+	if len(suspect) > 0 {
+		line += fmt.Sprintf("; no acknowledgement from %v (suspect)", suspect)
+	}
+	debugf("%s\n", line)
+}
+
+// readPump reads what the client sends until the connection breaks. This
+// also keeps control messages like pongs and closes flowing.
+func readPump(conn *websocket.Conn) {
+	defer conn.Close()
+	if *confirm {
+		deliveries.connect(conn)
+		defer deliveries.disconnect(conn)
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		switch {
+		case msg.Type == MessageHello && msg.V != ProtocolVersion:
+			reason := fmt.Sprintf("client speaks protocol %d, server %d; "+
+				"reload the page", msg.V, ProtocolVersion)
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(closeIncompatible, reason),
+				time.Now().Add(writeWait))
+			return
+		case msg.Type == MessageAck && *confirm:
+			deliveries.ack(msg.Version, conn.RemoteAddr().String())
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeliveryReportIsVerbose(t *testing.T) {
+	report := func() string {
+		l := &deliveryLog{pending: map[Version]*delivery{
+			7: {expected: []string{"a", "b"}, acked: map[string]bool{"a": true}},
+		}, order: []Version{7}}
+		return captureStdout(t, func() { l.report(7) })
+	}
+	if out := report(); out != "" {
+		t.Errorf("report printed %q without -verbose", out)
+	}
+	setFlag(t, verbose, true)
+	want := "Message 7: acknowledged by [a]; no acknowledgement from [b] (suspect)\n"
+	if out := report(); !strings.Contains(out, want) {
+		t.Errorf("report printed %q with -verbose, want %q", out, want)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+// AddTemplate makes tmpl, like a *template.Template built in memory, the
+// template for name. A file providing the same key takes precedence over
+// it for as long as the file exists.
+func (r *Reloader) AddTemplate(name string, tmpl Template) {
+	r.Lock()
+	defer r.Unlock()
+	if r.added == nil {
+		r.added = map[string]Template{}
+	}
+	r.added[name] = tmpl
+	// Loaders add their templates again on every scan, so the override
+	// is only reported the first time.
+	if path, ok := r.sources[name]; ok && r.shadowed[name] != path {
+		r.errors.printf("Warning: template %s added, but %s provides it.\n", name, path)
+		if r.shadowed == nil {
+			r.shadowed = map[string]string{}
+		}
+		r.shadowed[name] = path
+	}
+}
+
+// ParseString parses text as an html/template page called name, together
+// with the partials and with the functions, delimiters and options of
+// templates parsed from files, and adds it like AddTemplate.
+func (r *Reloader) ParseString(name, text string) error {
+	tmpl, err := HTML.parseString(r.parseSettings(), name, text, r.partialFiles(HTML)...)
+	if err != nil {
+		return err
+	}
+	r.AddTemplate(name, tmpl)
+	return nil
+}
+
+// parseString parses text with the engine into a template called name,
+// after the partials.
+func (e Engine) parseString(s parseSettings, name, text string, partials ...string) (Template, error) {
+	srcs, err := e.read(s, partials...)
+	if err != nil {
+		return nil, err
+	}
+	return e.parseSources(s, name, append(srcs, source{name, e.minify(s, text)})...)
+}
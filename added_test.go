@@ -0,0 +1,44 @@
+package main
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	"testing"
+)
+
+func TestAddTemplate(t *testing.T) {
+	r, _, _ := newTestReloader(t, map[string]string{"index.html": "index"})
+	r.AddTemplate("memory", htmltemplate.Must(htmltemplate.New("memory").Parse("<p>{{.}}</p>")))
+	if out := execute(t, r, "memory", "built"); out != "<p>built</p>" {
+		t.Errorf("memory = %q, want <p>built</p>", out)
+	}
+}
+
+func TestParseString(t *testing.T) {
+	r, _, _ := newTestReloader(t, map[string]string{
+		"index.html": "index",
+		"_nav.html":  "<nav>{{shout .}}</nav>",
+	}, WithFuncs(map[string]interface{}{"shout": strings.ToUpper}))
+
+	// Strings get the partials and functions of the files.
+	if err := r.ParseString("greeting", `{{template "_nav.html" .}}<p>hello</p>`); err != nil {
+		t.Fatal(err)
+	}
+	if out := execute(t, r, "greeting", "home"); out != "<nav>HOME</nav><p>hello</p>" {
+		t.Errorf("greeting = %q", out)
+	}
+
+	if err := r.ParseString("greeting", "<p>bye</p>"); err != nil {
+		t.Fatal(err)
+	}
+	if out := execute(t, r, "greeting", nil); out != "<p>bye</p>" {
+		t.Errorf("greeting = %q once replaced, want <p>bye</p>", out)
+	}
+
+	if err := r.ParseString("greeting", "<p>{{if}}</p>"); err == nil {
+		t.Error("ParseString succeeded with bad syntax")
+	}
+	if out := execute(t, r, "greeting", nil); out != "<p>bye</p>" {
+		t.Errorf("greeting = %q after a failed parse, want the last good one", out)
+	}
+}
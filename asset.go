@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// assetURL returns the URL of the static file name with a digest of its
+// contents, like "/static/css/app.css?v=3b2c9f0e4d1a7c85", for linking it
+// with {{asset "css/app.css"}} so browsers fetch it again when it changes.
+// A file missing from the static directories gets its URL without one.
+func (r *Reloader) assetURL(name string) string {
+	url := "/static/" + strings.TrimPrefix(path.Clean("/"+name), "/")
+	file, ok := r.StaticFile(name)
+	if !ok {
+		fmt.Printf("Warning: asset %s not found in %v.\n", name, r.static)
+		return url
+	}
+	hash, err := r.assetHash(file)
+	if err != nil {
+		fmt.Printf("Warning: unable to hash asset %s: %v\n", name, err)
+		return url
+	}
+	return url + "?v=" + hash
+}
+
+// assetHash returns the digest of the file at path from the cache,
+// hashing it on a miss.
+func (r *Reloader) assetHash(path string) (string, error) {
+	c := &r.assets
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hash, ok := c.hashes[path]; ok {
+		return hash, nil
+	}
+
+	sum, err := contentHash(path)
+	if err != nil {
+		return "", err
+	}
+	if c.hashes == nil {
+		c.hashes = map[string]string{}
+	}
+	hash := hex.EncodeToString(sum[:8])
+	c.hashes[path] = hash
+	return hash, nil
+}
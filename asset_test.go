@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAssetURLChangesWithContents(t *testing.T) {
+	r, _, watchers := newTestReloader(t, map[string]string{
+		"index.html": `<link href="{{asset "css/app.css"}}">`,
+	})
+	static := t.TempDir()
+	writeFiles(t, static, map[string]string{"css/app.css": "body { color: red }"})
+	if err := r.WatchStatic(static); err != nil {
+		t.Fatal(err)
+	}
+
+	before := execute(t, r, "index", nil)
+	if !strings.HasPrefix(before, `<link href="/static/css/app.css?v=`) {
+		t.Fatalf("index = %q, want a versioned /static/css/app.css", before)
+	}
+	if again := execute(t, r, "index", nil); again != before {
+		t.Errorf("index = %q rendered again, want %q", again, before)
+	}
+
+	edit(t, static, watchers, map[string]string{"css/app.css": "body { color: blue }"})
+	after := execute(t, r, "index", nil)
+	if after == before {
+		t.Errorf("index = %q after editing app.css, want a new query string", after)
+	}
+	if !strings.HasPrefix(after, `<link href="/static/css/app.css?v=`) {
+		t.Errorf("index = %q, want a versioned /static/css/app.css", after)
+	}
+}
+
+func TestMissingAssetURL(t *testing.T) {
+	r, _, _ := newTestReloader(t, map[string]string{
+		"index.html": `<link href="{{asset "css/missing.css"}}">`,
+	})
+	if err := r.WatchStatic(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	var out string
+	logged := captureStdout(t, func() { out = execute(t, r, "index", nil) })
+	if want := `<link href="/static/css/missing.css">`; out != want {
+		t.Errorf("index = %q, want %q", out, want)
+	}
+	if !strings.Contains(logged, "Warning: asset css/missing.css not found") {
+		t.Errorf("logged %q, want a warning about css/missing.css", logged)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"sort"
+	texttemplate "text/template"
+)
+
+// RenderBlock renders the template block defined with {{define}} in the
+// template name with data in response to req, like the "row" of a page
+// for an htmx request, buffered and with errors reported like pages. An
+// empty block, or the name ParseFiles gives the page itself, renders the
+// whole template.
+func (r *Reloader) RenderBlock(w http.ResponseWriter, req *http.Request, name, block string, data interface{}) error {
+	return render(r, w, req, name, data, nil, block)
+}
+
+// blockTemplate executes one of the templates defined in a Template.
+type blockTemplate struct {
+	Template
+	block string
+}
+
+func (b blockTemplate) Execute(w io.Writer, data interface{}) error {
+	return b.ExecuteTemplate(w, b.block, data)
+}
+
+// block returns tmpl executing the template it defines as block, and
+// false if it defines none. The whole template is returned for an empty
+// block or its own name.
+func block(tmpl Template, block string) (Template, bool) {
+	if block == "" || block == tmpl.Name() {
+		return tmpl, true
+	}
+	for _, name := range defined(tmpl) {
+		if name == block {
+			return blockTemplate{tmpl, block}, true
+		}
+	}
+	return nil, false
+}
+
+// defined returns the sorted names of the templates defined in tmpl.
+func defined(tmpl Template) []string {
+	var names []string
+	switch t := tmpl.(type) {
+	case *htmltemplate.Template:
+		for _, d := range t.Templates() {
+			names = append(names, d.Name())
+		}
+	case *texttemplate.Template:
+		for _, d := range t.Templates() {
+			names = append(names, d.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// missingBlock responds that the template name defines no block, listing
+// those it does, and returns the error.
+func missingBlock(r *Reloader, w http.ResponseWriter, host, name, block string, tmpl Template) error {
+	renderMissing(r, w, host, notFoundData{Key: block, In: name, Names: defined(tmpl)})
+	return fmt.Errorf("%w: %s in %s", ErrTemplateNotFound, block, name)
+}
@@ -0,0 +1,45 @@
+package main
+
+// brokenTemplate is the error of a template that failed to parse and
+// keeps serving its last good version.
+type brokenTemplate struct {
+	key string
+	err error
+}
+
+func (e *brokenTemplate) Error() string { return e.err.Error() }
+
+func (e *brokenTemplate) Unwrap() error { return e.err }
+
+// broken records that the template key failed to parse with err, and
+// returns the error to report.
+func (r *Reloader) broken(key string, err error) error {
+	r.Lock()
+	defer r.Unlock()
+	if r.parseErrors == nil {
+		r.parseErrors = map[string]error{}
+	}
+	r.parseErrors[key] = err
+	return &brokenTemplate{key, err}
+}
+
+// ParseErrors returns why the templates still serving their last good
+// version failed to parse since. A template is left out again once it
+// parses.
+func (r *Reloader) ParseErrors() map[string]error {
+	r.RLock()
+	defer r.RUnlock()
+	errs := make(map[string]error, len(r.parseErrors))
+	for key, err := range r.parseErrors {
+		errs[key] = err
+	}
+	return errs
+}
+
+// parseError returns why the template key failed to parse, if it's
+// serving its last good version.
+func (r *Reloader) parseError(key string) error {
+	r.RLock()
+	defer r.RUnlock()
+	return r.parseErrors[key]
+}
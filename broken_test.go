@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBrokenTemplateKeepsLastGoodVersion(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "one"})
+	if msg := edit(t, dir, watchers, map[string]string{"index.html": "{{if}}"}); msg.Type != MessageError {
+		t.Errorf("got %s message, want %s", msg.Type, MessageError)
+	}
+	if out := execute(t, r, "index", nil); out != "one" {
+		t.Errorf("index = %q while broken, want the last good one", out)
+	}
+	if _, ok := r.ParseErrors()["index"]; !ok {
+		t.Error("index isn't among ParseErrors() while broken")
+	}
+
+	if msg := edit(t, dir, watchers, map[string]string{"index.html": "two"}); msg.Type != MessageReload {
+		t.Errorf("got %s message once fixed, want %s", msg.Type, MessageReload)
+	}
+	if out := execute(t, r, "index", nil); out != "two" {
+		t.Errorf("index = %q once fixed, want two", out)
+	}
+	if errs := r.ParseErrors(); len(errs) > 0 {
+		t.Errorf("ParseErrors() = %v once fixed", errs)
+	}
+}
+
+func TestBrokenTemplateDoesntHoldBackOthers(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"index.html": "index",
+		"about.html": "about",
+	})
+	r.Coalesce = 50 * time.Millisecond
+	since := currentVersion()
+	edit(t, dir, watchers, map[string]string{
+		"index.html": "{{if}}",
+		"about.html": "about us",
+	})
+	waitFor(t, "both messages", func() bool { return currentVersion().Since(since) == 2 })
+	if msg := published(t, since); msg.Type != MessageReload {
+		t.Fatalf("got %s message last, want %s", msg.Type, MessageReload)
+	}
+	msg := published(t, since)
+	if !slices.Equal(msg.Files, []string{filepath.Join(dir, "about.html")}) {
+		t.Errorf("reload lists %v, want just about.html", msg.Files)
+	}
+	if v := msg.Templates["about"]; v != 2 {
+		t.Errorf("reload has about at version %d, want 2", v)
+	}
+	if out := execute(t, r, "about", nil); out != "about us" {
+		t.Errorf("about = %q, want about us", out)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer kept for reuse, so rendering one
+// huge page doesn't pin its memory for good.
+const maxPooledBuffer = 64 << 10
+
+// bufferPool holds the buffers pages are rendered into before being sent.
+// Each Reloader has its own, sized by its own pages. The zero value is
+// ready to use.
+type bufferPool struct {
+	pool sync.Pool
+}
+
+// get returns an empty buffer to render into.
+func (p *bufferPool) get() *bytes.Buffer {
+	if buf, ok := p.pool.Get().(*bytes.Buffer); ok {
+		return buf
+	}
+	return new(bytes.Buffer)
+}
+
+// put returns buf for reuse once its contents were sent.
+func (p *bufferPool) put(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	p.pool.Put(buf)
+}
+
+// ErrRenderTimeout is returned when a render is abandoned because its
+// request was done or RenderTimeout passed first.
+var ErrRenderTimeout = errors.New("render timed out")
+
+// execute executes tmpl with data into a buffer from the pool, giving up
+// once ctx is done. The abandoned execution finishes in the background and
+// returns its buffer to the pool then, as templates can't be interrupted.
+func (p *bufferPool) execute(ctx context.Context, tmpl Template, data interface{}) (*bytes.Buffer, error) {
+	buf := p.get()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- tmpl.Execute(buf, data)
+	}()
+	select {
+	case err := <-done:
+		return buf, err
+	case <-ctx.Done():
+		go func() {
+			<-done
+			p.put(buf)
+		}()
+		return nil, fmt.Errorf("%w: %v", ErrRenderTimeout, ctx.Err())
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// CheckFailure is a template that failed to load in a -check run.
+type CheckFailure struct {
+	File  string `json:"file,omitempty"`
+	Error string `json:"error"`
+}
+
+// CheckReport is what -check -json prints.
+type CheckReport struct {
+	OK       int            `json:"ok"`
+	Failed   int            `json:"failed"`
+	Failures []CheckFailure `json:"failures"`
+}
+
+// check loads every template the way the server does at startup, see
+// Preload, and reports those that failed to stdout, as JSON if asJSON. The
+// errors and warnings printed while loading go to stderr with asJSON, so
+// stdout is only the report. It returns whether all of them loaded, and
+// an error if the report couldn't be written.
+func check(r *Reloader, stdout, stderr io.Writer, asJSON bool) (bool, error) {
+	if asJSON {
+		r.errors.out = stderr
+	}
+	ok, errs := r.scan()
+
+	report := CheckReport{OK: ok, Failed: len(errs), Failures: []CheckFailure{}}
+	for _, err := range errs {
+		failure := CheckFailure{Error: err.Error()}
+		var changeErr *ChangeError
+		if errors.As(err, &changeErr) {
+			failure.File = changeErr.Path
+			failure.Error = changeErr.Err.Error()
+		}
+		report.Failures = append(report.Failures, failure)
+	}
+	sort.Slice(report.Failures, func(i, j int) bool {
+		return report.Failures[i].File < report.Failures[j].File
+	})
+
+	var err error
+	if asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		_, err = fmt.Fprintf(stdout, "%d ok, %d failed\n", report.OK, report.Failed)
+	}
+	return report.Failed == 0, err
+}
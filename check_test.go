@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var checkFiles = map[string]string{
+	"ok.html":     "ok",
+	"broken.html": "{{if}}",
+	"warns.html":  `{{template "missing"}}`,
+}
+
+func TestCheckJSON(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, checkFiles)
+	r := New(Root{Path: dir}, WithoutWatching())
+	var stdout, stderr bytes.Buffer
+	ok, err := check(r, &stdout, &stderr, true)
+	if ok || err != nil {
+		t.Errorf("check() = %v, %v, want a failure", ok, err)
+	}
+
+	var report CheckReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("stdout isn't only the report: %v\n%s", err, stdout.String())
+	}
+	if report.OK != 2 || report.Failed != 1 || len(report.Failures) != 1 {
+		t.Fatalf("report = %+v, want 2 ok and broken.html failed", report)
+	}
+	if f := report.Failures[0]; f.File != filepath.Join(dir, "broken.html") || !strings.Contains(f.Error, "missing value for if") {
+		t.Errorf("failure = %+v", f)
+	}
+	for _, want := range []string{"broken.html", `Warning: template warns: {{template "missing"}}`} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr doesn't show %q:\n%s", want, stderr.String())
+		}
+	}
+}
+
+func TestCheckSummary(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"ok.html": "ok"})
+	r := New(Root{Path: dir}, WithoutWatching())
+	var stdout bytes.Buffer
+	if ok, err := check(r, &stdout, &stdout, false); !ok || err != nil {
+		t.Errorf("check() = %v, %v, want success", ok, err)
+	}
+	if out := stdout.String(); out != "1 ok, 0 failed\n" {
+		t.Errorf("check printed %q", out)
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestCheckReportsWriteErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"ok.html": "ok"})
+	for _, asJSON := range []bool{false, true} {
+		r := New(Root{Path: dir}, WithoutWatching())
+		if _, err := check(r, failingWriter{}, &bytes.Buffer{}, asJSON); err == nil {
+			t.Errorf("check(asJSON=%v) = nil error writing to a failing writer", asJSON)
+		}
+	}
+}
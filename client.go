@@ -0,0 +1,271 @@
+package main
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultClientPath is where the reload client is served as an external
+// script, unless changed with -client-path. Pages reference it as
+// /livereload.{hash}.js instead, see clientURL, so browsers can cache it
+// for good.
+const DefaultClientPath = "/livereload.js"
+
+// closeIncompatible is the websocket close code for clients speaking
+// another protocol version. Clients reload the page to pick up a matching
+// script.
+const closeIncompatible = 4001
+
+// Ways of delivering the reload client to pages the server generates.
+const (
+	// ClientAuto references clientPath, unless the page's Content-Security-
+	// Policy blocks it but allows inline scripts.
+	ClientAuto = "auto"
+	// ClientExternal always references clientPath.
+	ClientExternal = "external"
+	// ClientInline always inlines the script.
+	ClientInline = "inline"
+)
+
+// wsPath is where clients connect to the websocket.
+const wsPath = "/ws"
+
+// VersionParam is the websocket query parameter a reconnecting client
+// passes the version of the last message it got in, to be sent the latest
+// one if it missed any while disconnected.
+const VersionParam = "version"
+
+//go:embed client.js
+var clientTemplate string
+
+// clientPlaceholders fills in the constants client.js refers to.
+var clientPlaceholders = strings.NewReplacer(
+	"{{PROTOCOL_VERSION}}", strconv.Itoa(ProtocolVersion),
+	"{{CLOSE_INCOMPATIBLE}}", strconv.Itoa(closeIncompatible),
+	"{{INFO_PATH}}", infoPath,
+	"{{WS_PATH}}", wsPath,
+	"{{VERSION_PARAM}}", VersionParam,
+)
+
+// clientJS is the reload client. It connects to the websocket in its data-ws
+// attribute, or else back to the host it was loaded from, which is the
+// page's host when it is inlined, and reconnects with a growing delay when
+// the connection drops, catching up on the messages it missed meanwhile.
+var clientJS = clientPlaceholders.Replace(clientTemplate)
+
+// clientSource returns the reload client, read from the -client-src file
+// when one is given so the script can be worked on like any other asset.
+// Its placeholders are filled in like client.js's, so a copy of it works.
+func (r *Reloader) clientSource() string {
+	if *clientSrc == "" {
+		return clientJS
+	}
+	src, err := r.asset(filepath.Clean(*clientSrc))
+	if err != nil {
+		r.errors.print(err)
+		return clientJS
+	}
+	return clientPlaceholders.Replace(string(src))
+}
+
+// clientURL returns the path pages load the client from, which changes
+// whenever the script does.
+func clientURL(src string) string {
+	sum := sha256.Sum256([]byte(src))
+	return strings.TrimSuffix(*clientPath, ".js") + "." + hex.EncodeToString(sum[:6]) + ".js"
+}
+
+// isClientPath reports whether path is the client's path or a hashed
+// variant.
+func isClientPath(path string) bool {
+	return strings.HasPrefix(path, strings.TrimSuffix(*clientPath, ".js")+".") &&
+		strings.HasSuffix(path, ".js")
+}
+
+// clientScript renders the tag that loads the reload client. Its data is a
+// clientTag.
+var clientScript = template.Must(template.New("client").Parse(
+	`{{if .Inline}}<script{{if .Nonce}} nonce="{{.Nonce}}"{{end}}{{if .WS}} data-ws="{{.WS}}"{{end}}>{{.JS}}</script>` +
+		`{{else}}<script src="{{.Path}}"{{if .Nonce}} nonce="{{.Nonce}}"{{end}}{{if .WS}} data-ws="{{.WS}}"{{end}}></script>{{end}}
+`))
+
+type clientTag struct {
+	Inline bool
+	Nonce  string
+	Path   string
+	JS     template.JS
+	// WS is the websocket URL the client connects to, if known.
+	WS string
+}
+
+// ClientFuncs returns the request funcs giving pages served for req the
+// livereload function, which emits the tag loading the reload client,
+// connected to the websocket at the scheme and host req reached the server
+// with, proxies included. Templates parse with a livereload emitting
+// nothing, which is what they get with -prod.
+func (r *Reloader) ClientFuncs(w http.ResponseWriter, req *http.Request) map[string]interface{} {
+	if *production {
+		return nil
+	}
+	return map[string]interface{}{
+		"livereload": func() (template.HTML, error) {
+			return r.clientSnippet(w, req)
+		},
+	}
+}
+
+// clientSnippet returns the tag loading the reload client into the page w
+// responds to req with.
+func (r *Reloader) clientSnippet(w http.ResponseWriter, req *http.Request) (template.HTML, error) {
+	tag := r.clientTag(w, req.Host, *clientMode)
+	tag.WS = websocketURL(req)
+	var buf strings.Builder
+	if err := clientScript.Execute(&buf, tag); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// websocketURL returns the URL of the websocket as seen by the client that
+// sent req.
+func websocketURL(req *http.Request) string {
+	scheme := "ws"
+	if req.TLS != nil || strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "wss"
+	}
+	host := req.Host
+	if forwarded := req.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host = forwarded
+	}
+	return scheme + "://" + host + wsPath
+}
+
+// warnedCSP remembers the policies already warned about, so a page reloaded
+// over and over only warns once.
+var warnedCSP sync.Map
+
+// clientTag decides how to deliver the client to a page, based on mode and
+// on the Content-Security-Policy already set on the response.
+func (r *Reloader) clientTag(w http.ResponseWriter, host, mode string) clientTag {
+	// The page loads the client itself, so it isn't injected again.
+	if iw, ok := w.(*injectingWriter); ok {
+		iw.hasClient = true
+	}
+	src := r.clientSource()
+	tag := clientTag{
+		Inline: mode == ClientInline,
+		Path:   clientURL(src),
+		JS:     template.JS(src),
+	}
+
+	policy := w.Header().Get("Content-Security-Policy")
+	if policy == "" {
+		return tag
+	}
+	directive, sources := scriptSources(policy)
+	tag.Nonce = cspNonce(sources)
+	if tag.Nonce != "" {
+		return tag
+	}
+
+	if mode == ClientAuto && !allowsExternal(sources, host) &&
+		hasSource(sources, "'unsafe-inline'") {
+		tag.Inline = true
+	}
+
+	blocked := !allowsExternal(sources, host)
+	if tag.Inline {
+		blocked = !hasSource(sources, "'unsafe-inline'")
+	}
+	if _, warned := warnedCSP.LoadOrStore(policy, true); blocked && !warned {
+		fmt.Printf("Warning: Content-Security-Policy directive %q blocks the "+
+			"live reload script; pages will not reload.\n", directive)
+	}
+	return tag
+}
+
+// scriptSources returns the directive governing scripts in policy, and its
+// source list. A policy without one allows every script.
+func scriptSources(policy string) (string, []string) {
+	directives := map[string][]string{}
+	for _, d := range strings.Split(policy, ";") {
+		fields := strings.Fields(d)
+		if len(fields) > 0 {
+			directives[strings.ToLower(fields[0])] = fields[1:]
+		}
+	}
+	for _, name := range []string{"script-src-elem", "script-src", "default-src"} {
+		if sources, ok := directives[name]; ok {
+			return name, sources
+		}
+	}
+	return "", []string{"*"}
+}
+
+func cspNonce(sources []string) string {
+	for _, s := range sources {
+		if strings.HasPrefix(s, "'nonce-") && strings.HasSuffix(s, "'") {
+			return strings.TrimSuffix(strings.TrimPrefix(s, "'nonce-"), "'")
+		}
+	}
+	return ""
+}
+
+func hasSource(sources []string, source string) bool {
+	for _, s := range sources {
+		if strings.EqualFold(s, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsExternal reports whether sources allow loading clientPath from host.
+func allowsExternal(sources []string, host string) bool {
+	for _, s := range sources {
+		switch strings.ToLower(s) {
+		case "*", "'self'", "http:", "https:":
+			return true
+		}
+		if strings.Contains(s, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// getServeClient serves the reload client. The hashed URL of the current
+// script is cached for good, anything else must be revalidated.
+func getServeClient(reloader *Reloader) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		src := reloader.clientSource()
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		if r.URL.Path == clientURL(src) {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		fmt.Fprint(w, src)
+	})
+}
+
+// serveClientOr serves the reload client at its hashed URLs, which the mux
+// can't match, and hands every other request to next.
+func serveClientOr(reloader *Reloader, next http.Handler) http.HandlerFunc {
+	client := getServeClient(reloader)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isClientPath(r.URL.Path) {
+			client(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
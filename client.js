@@ -0,0 +1,103 @@
+(function() {
+    var protocolVersion = {{PROTOCOL_VERSION}};
+    var closeIncompatible = {{CLOSE_INCOMPATIBLE}};
+    // Reconnects wait twice as long after each failure, up to maxDelay.
+    var minDelay = 500;
+    var maxDelay = 30000;
+    var delay = minDelay;
+    // The version of the last message, so a reconnect catches up on
+    // those missed in between.
+    var version = null;
+
+    var script = document.currentScript;
+    var origin = script && script.src ? new URL(script.src) : window.location;
+    var host = origin.host;
+    var scheme = origin.protocol === "https:" ? "wss://" : "ws://";
+    var log = console.log.bind(console, "livereload:");
+    var warn = console.warn.bind(console, "livereload:");
+
+    // dispatch fires a cancelable "livereload:<name>" event on the
+    // document. It returns false if a listener called preventDefault(), in
+    // which case the default action is skipped.
+    function dispatch(name, detail) {
+        return document.dispatchEvent(new CustomEvent("livereload:" + name, {
+            detail: detail,
+            cancelable: true
+        }));
+    }
+
+    function connect(url) {
+        var conn = new WebSocket(version === null ? url :
+            url + (url.indexOf("?") < 0 ? "?" : "&") +
+            "{{VERSION_PARAM}}=" + version);
+        conn.onopen = function() {
+            log("connected to", url);
+            delay = minDelay;
+            conn.send(JSON.stringify({v: protocolVersion, type: "hello"}));
+            dispatch("connected", null);
+        }
+        conn.onerror = function() {
+            dispatch("error", null);
+        }
+        conn.onclose = function(evt) {
+            if (evt.code === closeIncompatible) {
+                warn(evt.reason);
+                window.location.reload();
+                return;
+            }
+            log("disconnected, reconnecting in", delay / 1000, "s");
+            setTimeout(function() {
+                connect(url);
+            }, delay);
+            delay = Math.min(delay * 2, maxDelay);
+        }
+        conn.onmessage = function(evt) {
+            var msg;
+            try {
+                msg = JSON.parse(evt.data);
+            } catch (e) {
+                warn("ignoring malformed message", evt.data);
+                return;
+            }
+            if (msg.v !== protocolVersion) {
+                warn("ignoring message with unknown version", msg.v);
+                return;
+            }
+            version = msg.version;
+            if (msg.ack) {
+                conn.send(JSON.stringify({
+                    v: protocolVersion, type: "ack", version: msg.version
+                }));
+            }
+            (msg.warnings || []).forEach(function(warning) {
+                warn(warning);
+            });
+            if (msg.type === "error") {
+                if (dispatch("error", msg)) {
+                    warn(msg.error);
+                }
+                return;
+            }
+            // Reloads targeted at other pages don't concern this one.
+            if (msg.paths && msg.paths.indexOf(window.location.pathname) < 0) {
+                return;
+            }
+            if (msg.type === "build_complete" && dispatch("reload", msg)) {
+                log("reloading");
+                window.location.reload();
+            }
+        }
+    }
+
+    if (script && script.dataset.ws) {
+        connect(script.dataset.ws);
+        return;
+    }
+    // Ask the server which transports it offers, falling back to the
+    // websocket at its usual path.
+    fetch("//" + host + "{{INFO_PATH}}")
+        .then(function(resp) { return resp.json(); })
+        .then(function(info) { return info.transports.websocket || "{{WS_PATH}}"; })
+        .catch(function() { return "{{WS_PATH}}"; })
+        .then(function(path) { connect(scheme + host + path); });
+})();
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// getWith returns the body of the response to a GET of path with headers.
+func (s *TestServer) getWith(path string, headers map[string]string) string {
+	s.t.Helper()
+	req, err := http.NewRequest("GET", s.Server.URL+path, nil)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestLivereloadFunc(t *testing.T) {
+	s := NewTestServer(t)
+	s.WriteTemplate("index.html", "<html><body>{{livereload}}</body></html>")
+	host := strings.TrimPrefix(s.Server.URL, "http://")
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"direct", nil, "ws://" + host + wsPath},
+		{"behind a proxy", map[string]string{
+			"X-Forwarded-Proto": "https",
+			"X-Forwarded-Host":  "example.com",
+		}, "wss://example.com" + wsPath},
+	}
+	for _, tt := range tests {
+		body := s.getWith("/", tt.headers)
+		if want := `data-ws="` + tt.want + `"`; !strings.Contains(body, want) {
+			t.Errorf("%s: page = %q, want it to contain %s", tt.name, body, want)
+		}
+		if n := strings.Count(body, "<script"); n != 1 {
+			t.Errorf("%s: page = %q, want the client's script tag once", tt.name, body)
+		}
+	}
+}
+
+func TestLivereloadFuncInProduction(t *testing.T) {
+	setFlag(t, production, true)
+	s := NewTestServer(t)
+	s.WriteTemplate("index.html", "<html><body>{{livereload}}</body></html>")
+	if body := s.get("/"); body != "<html><body></body></html>" {
+		t.Errorf("page = %q, want no client with -prod", body)
+	}
+}
+
+func TestClientSrcPlaceholders(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "client.js")
+	if err := os.WriteFile(src, []byte(clientTemplate), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, clientSrc, src)
+	s := NewTestServer(t)
+	if body := s.get(DefaultClientPath); body != clientJS {
+		t.Errorf("served a copy of client.js as %q, want it filled in like the embedded one", body)
+	}
+
+	if err := os.WriteFile(src, []byte("connect({{WS_PATH}}, {{VERSION_PARAM}})"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := "connect(" + wsPath + ", " + VersionParam + ")"
+	waitFor(t, "the edited client", func() bool { return s.get(DefaultClientPath) == want })
+}
+
+func TestServedClientContract(t *testing.T) {
+	s := NewTestServer(t)
+	body := s.get(DefaultClientPath)
+	for _, want := range []string{
+		"var protocolVersion = " + strconv.Itoa(ProtocolVersion) + ";",
+		`new CustomEvent("livereload:" + name`,
+		"cancelable: true",
+		`dispatch("connected", null)`,
+		`dispatch("error", msg)`,
+		`dispatch("reload", msg)`,
+		"msg.v !== protocolVersion",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("served client lacks %s", want)
+		}
+	}
+	if strings.Contains(body, "{{") {
+		t.Errorf("served client has placeholders left: %s", body)
+	}
+}
+
+func TestSamplePageClient(t *testing.T) {
+	page, err := os.ReadFile("index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewTestServer(t)
+	s.WriteTemplate("index.html", string(page))
+	body := s.getWith("/", map[string]string{
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "example.com",
+	})
+	if want := `data-ws="wss://example.com` + wsPath + `"`; !strings.Contains(body, want) {
+		t.Errorf("sample page = %q, want it to contain %s", body, want)
+	}
+	if strings.Contains(body, "new WebSocket") {
+		t.Errorf("sample page = %q, want no hand-rolled client", body)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "errors"
+
+// errReloaderClosed is returned when the watcher is recreated after Close.
+var errReloaderClosed = errors.New("reloader closed")
+
+// Close stops watching as if the context given to Watch was done, closes
+// the watcher and disconnects the clients. Get keeps returning the
+// templates loaded until then. Closing again does nothing.
+func (r *Reloader) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		// The flag is set holding the broadcast lock too, so clients see
+		// it whether they're waiting for a broadcast yet or not.
+		broadcastCond.L.Lock()
+		r.Lock()
+		r.closed = true
+		cancel := r.cancel
+		watcher := r.Watcher
+		r.Unlock()
+		broadcastCond.Broadcast()
+		broadcastCond.L.Unlock()
+
+		if cancel != nil {
+			cancel()
+		}
+		r.stop()
+		err = watcher.Close()
+	})
+	return err
+}
+
+// isClosed reports whether Close was called.
+func (r *Reloader) isClosed() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.closed
+}
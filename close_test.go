@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetAfterClose(t *testing.T) {
+	r, _, _ := newTestReloader(t, map[string]string{"index.html": "one"})
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("closing again: %v", err)
+	}
+	if out := execute(t, r, "index", nil); out != "one" {
+		t.Errorf("index = %q once closed, want one", out)
+	}
+}
+
+func TestCloseDisconnectsClients(t *testing.T) {
+	s := NewTestServer(t)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expectDisconnected(t, s.client)
+
+	// A client connecting once closed isn't waiting for a broadcast yet
+	// when Close wakes the others.
+	expectDisconnected(t, s.Client())
+}
+
+// expectDisconnected fails the test unless c is disconnected without being
+// sent anything.
+func expectDisconnected(t *testing.T, c *TestClient) {
+	t.Helper()
+	start := time.Now()
+	if msg, ok := c.Next(testTimeout); ok {
+		t.Errorf("client got a %s message, want it disconnected", msg.Type)
+	} else if time.Since(start) >= testTimeout {
+		t.Error("client isn't disconnected")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Collisions decides which file provides a template key that files in
+// several roots have.
+type Collisions int
+
+const (
+	// OverrideCollisions lets later roots override the templates of
+	// earlier ones, as for a theme overriding a base.
+	OverrideCollisions Collisions = iota
+	// RefuseCollisions keeps the template of the first root having the
+	// key and reports the files of later roots instead of using them.
+	RefuseCollisions
+)
+
+// WithCollisions sets how keys provided by files in several roots are
+// handled, OverrideCollisions by default. Files in the same root having the
+// same key, like "index.html" and "index.tmpl", are always reported, and
+// the one with the extension listed first is used. Root.Prefix keeps the
+// keys of roots apart. Sources tells which file provides each key.
+func WithCollisions(policy Collisions) Option {
+	return optionFunc(func(r *Reloader) { r.collisions = policy })
+}
+
+// rootOrder returns the indexes of roots in the order they're looked
+// through for the file providing a key.
+func (r *Reloader) rootOrder(roots []Root) []int {
+	order := make([]int, len(roots))
+	for i := range order {
+		if r.collisions == RefuseCollisions {
+			order[i] = i
+		} else {
+			order[i] = len(roots) - 1 - i
+		}
+	}
+	return order
+}
+
+// prefers reports whether the template file a rather than b should
+// provide the key they share.
+func (r *Reloader) prefers(a, b string) bool {
+	rootA, i, _ := r.fileRoot(a)
+	_, j, _ := r.fileRoot(b)
+	if i != j {
+		if r.collisions == RefuseCollisions {
+			return i < j
+		}
+		return i > j
+	}
+	extA, _ := rootA.templateExt(a)
+	extB, _ := rootA.templateExt(b)
+	for _, ext := range rootA.exts() {
+		if ext == extA && ext != extB {
+			return true
+		}
+		if ext == extB && ext != extA {
+			return false
+		}
+	}
+	return a < b
+}
+
+// collided reports the file other having the key used provides, unless
+// it's only overridden as intended.
+func (r *Reloader) collided(key, used, other string) {
+	if filepath.Clean(used) == filepath.Clean(other) {
+		return
+	}
+	if _, err := os.Stat(other); err != nil {
+		return
+	}
+	_, i, _ := r.fileRoot(used)
+	_, j, _ := r.fileRoot(other)
+	if i != j && r.collisions == OverrideCollisions {
+		return
+	}
+	r.errors.print(fmt.Errorf("Template %s is provided by both %s and %s; using %s",
+		key, used, other, used))
+}
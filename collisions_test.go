@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// expectCollision fails the test unless errs receives an error naming
+// both files.
+func expectCollision(t *testing.T, errs <-chan error, a, b string) {
+	t.Helper()
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), a) || !strings.Contains(err.Error(), b) {
+			t.Errorf("got error %v, want one naming %s and %s", err, a, b)
+		}
+	case <-time.After(testTimeout):
+		t.Errorf("the collision of %s and %s wasn't reported", a, b)
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base/index.html":  "base",
+		"theme/index.html": "theme",
+		"base/about.html":  "about",
+	})
+	base, theme := filepath.Join(dir, "base"), filepath.Join(dir, "theme")
+	r, watchers := startTestReloader(t, Root{Path: base}, Root{Path: theme},
+		WithCollisions(RefuseCollisions))
+	errs := r.ErrorLog()
+	r.Scan()
+	expectCollision(t, errs, filepath.Join(base, "index.html"), filepath.Join(theme, "index.html"))
+	if out := execute(t, r, "index", nil); out != "base" {
+		t.Errorf("index = %q, want the first root's", out)
+	}
+
+	// A file created later doesn't take over either.
+	since := currentVersion()
+	writeFiles(t, theme, map[string]string{"about.html": "theme about"})
+	watchers.last().Send(filepath.Join(theme, "about.html"), fsnotify.Create)
+	published(t, since)
+	expectCollision(t, errs, filepath.Join(base, "about.html"), filepath.Join(theme, "about.html"))
+	if src := r.Sources()["about"]; src != filepath.Join(base, "about.html") {
+		t.Errorf("about is provided by %s, want the first root's", src)
+	}
+}
+
+func TestCollisionsOverride(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base/index.html":  "base",
+		"theme/index.html": "theme",
+	})
+	r, _ := startTestReloader(t, Root{Path: filepath.Join(dir, "base")},
+		Root{Path: filepath.Join(dir, "theme")})
+	if out := execute(t, r, "index", nil); out != "theme" {
+		t.Errorf("index = %q, want the later root's", out)
+	}
+}
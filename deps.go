@@ -0,0 +1,59 @@
+package main
+
+import "sort"
+
+// track records the partials the page key includes, see Dependencies, and
+// checks the templates it invokes are defined.
+func (r *Reloader) track(key string) {
+	r.forgetCycles(key)
+	deps := r.templatesUsed(key)[1:]
+	sort.Strings(deps)
+	r.Lock()
+	if r.deps == nil {
+		r.deps = map[string][]string{}
+	}
+	r.deps[key] = deps
+	r.Unlock()
+	r.checkRefs(key)
+}
+
+// untrack forgets the partials of the evicted page key.
+func (r *Reloader) untrack(key string) {
+	r.forgetCycles(key)
+	r.Lock()
+	defer r.Unlock()
+	delete(r.deps, key)
+	delete(r.undefined, key)
+}
+
+// Dependencies returns the keys of the partials each page includes with
+// {{template}} or {{block}}, directly or through other partials, as of its
+// last parse.
+func (r *Reloader) Dependencies() map[string][]string {
+	r.RLock()
+	defer r.RUnlock()
+	deps := make(map[string][]string, len(r.deps))
+	for key, partials := range r.deps {
+		deps[key] = append([]string(nil), partials...)
+	}
+	return deps
+}
+
+// reloadDependents reparses the pages including the partial key, leaving
+// the others alone.
+func (r *Reloader) reloadDependents(partial string) error {
+	r.RLock()
+	sources := map[string]string{}
+	for key, partials := range r.deps {
+		for _, k := range partials {
+			if k == partial {
+				sources[key] = r.sources[key]
+				break
+			}
+		}
+	}
+	r.RUnlock()
+	debugf("Partial %s is included by %d pages; reparsing them.\n",
+		partial, len(sources))
+	return r.reparse(sources)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DiagnosticKey is the template rendered instead of the built-in diagnostic
+// page when a template fails to execute, if there is one.
+const DiagnosticKey = "500-dev"
+
+// diagnostic is shown in place of a page whose template failed to execute,
+// unless running with -prod. It reloads like any other page, so fixing the
+// template brings the page back.
+var diagnostic = template.Must(template.Must(clientScript.Clone()).New("diagnostic").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Error: {{.Key}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { background: #f6f6f6; padding: 1em 0; overflow: auto; }
+.line { display: block; padding: 0 1em; }
+.line span { display: inline-block; width: 3em; color: #999; user-select: none; }
+.failing { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Template {{.Key}} failed</h1>
+<pre>{{.Error}}</pre>
+{{with .Data}}<p>Rendered with data of type <code>{{.}}</code>.</p>{{end}}
+{{if .Lines}}
+<h2>{{.File}}</h2>
+<pre>{{range .Lines}}<code class="line{{if .Failing}} failing{{end}}"><span>{{.Number}}</span>{{.Text}}</code>{{end}}</pre>
+{{end}}
+{{template "client" .Client}}
+</body>
+</html>
+`))
+
+// genericError is shown with -prod in place of a page whose template failed.
+const genericError = `<!DOCTYPE html>
+<html>
+<head><title>Internal server error</title></head>
+<body><h1>Internal server error</h1><p>Something went wrong rendering this page.</p></body>
+</html>
+`
+
+// errorLocation finds the template name and line in execution errors like
+// `template: page.html:12:7: executing "page.html" at <.Name>: ...`.
+var errorLocation = regexp.MustCompile(`template: ?([^:\s]+):(\d+):`)
+
+type diagnosticData struct {
+	Client clientTag
+	Key    string
+	Error  string
+	// Data is the type of the data the template was executed with.
+	Data string
+	// File is the file the error points at, and Line the failing line in
+	// it, 0 if the error doesn't say.
+	File  string
+	Line  int
+	Lines []sourceLine
+}
+
+type sourceLine struct {
+	Number  int
+	Text    string
+	Failing bool
+}
+
+// render executes the template name, or the block it defines if given, in
+// response to req, with the request funcs applied to a copy of it. Output
+// is buffered, so a failing template produces an error page rather than
+// half a page, a successful one is sent with its Content-Length, and HEAD
+// requests get the same headers as GET. With -prod, pages carry the
+// template's load time as Last-Modified and conditional requests are
+// answered with 304 Not Modified.
+func render(r *Reloader, w http.ResponseWriter, req *http.Request, name string, data interface{}, funcs map[string]interface{}, blockName string) (err error) {
+	tmpl, err := r.Get(name)
+	if errors.Is(err, ErrTemplateNotFound) {
+		renderNotFound(r, w, req.Host, name)
+		return err
+	}
+	if err == nil {
+		tmpl, err = r.withFuncs(name, tmpl, funcs)
+	}
+	if err != nil {
+		renderDiagnostic(r, w, req.Host, name, err, data)
+		return err
+	}
+	full := tmpl
+	tmpl, ok := block(full, blockName)
+	if !ok {
+		return missingBlock(r, w, req.Host, name, blockName, full)
+	}
+	ctx := req.Context()
+	if r.RenderTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.RenderTimeout)
+		defer cancel()
+	}
+	buf, err := r.buffers.execute(ctx, tmpl, data)
+	if errors.Is(err, ErrRenderTimeout) {
+		fmt.Println("Error rendering", name+":", err)
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		return err
+	}
+	defer r.buffers.put(buf)
+	if err != nil {
+		fmt.Println("Error rendering", name+":", err)
+		renderDiagnostic(r, w, req.Host, name, err, data)
+		return err
+	}
+
+	r.recordSample(name, data)
+
+	// In development every request is rendered afresh, data may change
+	// without the template changing.
+	var modtime time.Time
+	if *production {
+		modtime = r.Loaded(name)
+	}
+	// A Content-Type set by the caller is kept.
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", r.contentType(name))
+	}
+	http.ServeContent(w, req, name, modtime, bytes.NewReader(buf.Bytes()))
+	return nil
+}
+
+// renderDiagnostic responds with the error key failed with when executed
+// with pageData, showing the template source in development. With -prod
+// only a generic error page is shown, never exposing the source. The error
+// template for 500 replaces both if there is one.
+func renderDiagnostic(reloader *Reloader, w http.ResponseWriter, host, key string, err error, pageData interface{}) {
+	if reloader.renderError(w, host, http.StatusInternalServerError, key, err) {
+		return
+	}
+	if *production {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, genericError)
+		return
+	}
+
+	data := diagnosticData{
+		Client: reloader.clientTag(w, host, *clientMode),
+		Key:    key,
+		Error:  err.Error(),
+		Data:   fmt.Sprintf("%T", pageData),
+	}
+	data.File, data.Line = reloader.errorSource(key, err)
+	if src, err := os.ReadFile(data.File); err == nil && data.File != "" {
+		for i, text := range strings.Split(strings.TrimSuffix(string(src), "\n"), "\n") {
+			data.Lines = append(data.Lines, sourceLine{
+				Number:  i + 1,
+				Text:    text,
+				Failing: i+1 == data.Line,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	tmpl := Template(diagnostic)
+	if custom, err := reloader.Get(DiagnosticKey); err == nil && key != DiagnosticKey {
+		tmpl = custom
+	}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	buf.WriteTo(w)
+}
+
+// errorSource returns the file and line err of the template key points at.
+// Without a location in the error, it is the page's own file and line 0.
+func (r *Reloader) errorSource(key string, err error) (string, int) {
+	r.RLock()
+	defer r.RUnlock()
+	file := r.sources[key]
+
+	m := errorLocation.FindStringSubmatch(err.Error())
+	if m == nil {
+		return file, 0
+	}
+	line, _ := strconv.Atoi(m[2])
+	if filepath.Base(file) == m[1] {
+		return file, line
+	}
+	// The error is in a partial, named by its file in the template set.
+	for _, path := range r.partials {
+		if filepath.Base(path) == m[1] {
+			return path, line
+		}
+	}
+	return file, 0
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExecutionErrorPage(t *testing.T) {
+	s := NewTestServer(t)
+	s.WriteTemplate("broken.html", "<p>{{len 3}}</p>")
+	s.ExpectReload(testTimeout)
+
+	status, body := s.fetch("/broken")
+	if status != http.StatusInternalServerError {
+		t.Errorf("status %d, want %d", status, http.StatusInternalServerError)
+	}
+	for _, want := range []string{"broken", "len of type int", "Rendered with data of type"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("error page doesn't show %q:\n%s", want, body)
+		}
+	}
+
+	setFlag(t, production, true)
+	status, body = s.fetch("/broken")
+	if status != http.StatusInternalServerError {
+		t.Errorf("status %d with -prod, want %d", status, http.StatusInternalServerError)
+	}
+	if body != genericError {
+		t.Errorf("error page with -prod = %q, want the generic one", body)
+	}
+}
+
+func TestMissingPageIsNotFound(t *testing.T) {
+	s := NewTestServer(t)
+	if status, _ := s.fetch("/missing"); status != http.StatusNotFound {
+		t.Errorf("status %d, want %d", status, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// AddDir adds a root of templates while the Reloader is running, like a
+// plugin's template directory. Its templates are loaded right away and
+// override those of the roots added before it.
+func (r *Reloader) AddDir(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	root := r.setUp(Root{Path: path})
+	for _, existing := range r.rootList() {
+		if filepath.Clean(existing.Path) == filepath.Clean(path) {
+			return fmt.Errorf("%s is already a root", path)
+		}
+	}
+
+	r.rootsMu.Lock()
+	r.roots = append(r.roots[:len(r.roots):len(r.roots)], root)
+	r.rootsMu.Unlock()
+
+	if !root.file {
+		r.loadIgnore(path)
+	}
+	if r.pollInterval > 0 {
+		r.pollRoot(path)
+	} else {
+		r.RLock()
+		watcher := r.Watcher
+		r.RUnlock()
+		var err error
+		if root.file {
+			err = watcher.Add(root.dir())
+		} else {
+			err = r.watchTree(watcher, path)
+		}
+		if err != nil {
+			r.errors.print(err)
+		}
+	}
+
+	fmt.Printf("Root %s added; loading its templates.\n", path)
+	r.reloadRoots()
+	return nil
+}
+
+// RemoveDir removes a root added to New or with AddDir. Its templates are
+// dropped, falling back to those of other roots having the same keys.
+func (r *Reloader) RemoveDir(path string) error {
+	path = filepath.Clean(path)
+	r.rootsMu.Lock()
+	roots := make([]Root, 0, len(r.roots))
+	var removed *Root
+	for _, root := range r.roots {
+		if filepath.Clean(root.Path) == path {
+			root := root
+			removed = &root
+			continue
+		}
+		roots = append(roots, root)
+	}
+	r.roots = roots
+	r.rootsMu.Unlock()
+	if removed == nil {
+		return fmt.Errorf("%s is not a root", path)
+	}
+
+	r.Lock()
+	watcher := r.Watcher
+	var dirs []string
+	for dir := range r.dirs {
+		// Directories of other roots nested in the removed one stay.
+		if isWithin(dir, path) && !r.isRootDir(dir) {
+			dirs = append(dirs, dir)
+			delete(r.dirs, dir)
+		}
+	}
+	delete(r.liveignore, removed.Path)
+	poller := r.pollers[path]
+	delete(r.pollers, path)
+	r.Unlock()
+
+	for _, dir := range dirs {
+		watcher.Remove(dir)
+	}
+	if poller != nil {
+		poller.Close()
+	}
+
+	fmt.Printf("Root %s removed; dropping its templates.\n", path)
+	r.reloadRoots()
+	return nil
+}
+
+// isRootDir reports whether dir lies within one of the roots.
+func (r *Reloader) isRootDir(dir string) bool {
+	for _, root := range r.rootList() {
+		if !root.file && isWithin(dir, root.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// pollRoot starts polling the root at path with WithPolling.
+func (r *Reloader) pollRoot(path string) {
+	p := newPoller(r.pollInterval, []string{path}, r.walkOptions())
+	r.Lock()
+	if r.pollers == nil {
+		r.pollers = map[string]*Poller{}
+	}
+	r.pollers[filepath.Clean(path)] = p
+	r.Unlock()
+	r.AddSource(p)
+}
+
+// reloadRoots reloads every template after the roots changed, and tells
+// the clients.
+func (r *Reloader) reloadRoots() {
+	r.changes.handling.Lock()
+	defer r.changes.handling.Unlock()
+	r.Scan()
+	publish(Message{Type: MessageReload})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestAddAndRemoveDir(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"index.html": "index",
+		"card.html":  "base card",
+	})
+	plugin := t.TempDir()
+	writeFiles(t, plugin, map[string]string{
+		"card.html":        "plugin card",
+		"widgets/map.html": "map",
+	})
+
+	if err := r.AddDir(plugin); err != nil {
+		t.Fatal(err)
+	}
+	if names, want := r.Names(), []string{"card", "index", "widgets/map"}; !slices.Equal(names, want) {
+		t.Errorf("Names() = %v after AddDir, want %v", names, want)
+	}
+	if out := execute(t, r, "card", nil); out != "plugin card" {
+		t.Errorf("card = %q, want the added root's", out)
+	}
+	for _, sub := range []string{"", "widgets"} {
+		if !watchers.last().Watched(filepath.Join(plugin, sub)) {
+			t.Errorf("%s isn't watched after AddDir", filepath.Join(plugin, sub))
+		}
+	}
+	// Its files reload like any other.
+	edit(t, plugin, watchers, map[string]string{"widgets/map.html": "map2"})
+	if out := execute(t, r, "widgets/map", nil); out != "map2" {
+		t.Errorf("widgets/map = %q, want map2", out)
+	}
+
+	if err := r.RemoveDir(plugin); err != nil {
+		t.Fatal(err)
+	}
+	if names, want := r.Names(), []string{"card", "index"}; !slices.Equal(names, want) {
+		t.Errorf("Names() = %v after RemoveDir, want %v", names, want)
+	}
+	if _, err := r.Get("widgets/map"); err == nil {
+		t.Error("Get(widgets/map) succeeded after RemoveDir")
+	}
+	if out := execute(t, r, "card", nil); out != "base card" {
+		t.Errorf("card = %q, want it back from %s", out, dir)
+	}
+	if watchers.last().Watched(filepath.Join(plugin, "widgets")) {
+		t.Error("the removed root is still watched")
+	}
+	if err := r.RemoveDir(plugin); err == nil {
+		t.Error("removing it again succeeded")
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+)
+
+// EmbeddedEnv is the environment variable that, when set to anything,
+// makes NewEmbedded serve the embedded templates only, without watching
+// anything, as in production.
+const EmbeddedEnv = "LIVERELOAD_EMBEDDED"
+
+// FSLoader is a Loader of the files in FS, like templates embedded with
+// go:embed. It reports no changes.
+type FSLoader struct {
+	FS fs.FS
+}
+
+func (l FSLoader) List() ([]string, error) {
+	var names []string
+	err := fs.WalkDir(l.FS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			names = append(names, path)
+		}
+		return err
+	})
+	return names, err
+}
+
+func (l FSLoader) Read(name string) ([]byte, error) {
+	return fs.ReadFile(l.FS, name)
+}
+
+func (l FSLoader) Changes() <-chan string { return nil }
+
+// NewEmbedded returns a Reloader serving the templates in fsys, like an
+// embed.FS narrowed to the templates directory with fs.Sub, with the
+// files in the overlay directory taking precedence. The overlay is watched
+// like any root, so its files reload live, and removing one falls back to
+// the embedded copy. Both have the same keys, so Get finds the same names
+// either way. Without an overlay, or with EmbeddedEnv set, nothing is
+// watched. Partials in the overlay aren't used by embedded pages.
+func NewEmbedded(fsys fs.FS, overlay string, options ...Option) *Reloader {
+	options = append([]Option{WithLoader(FSLoader{fsys})}, options...)
+	if overlay == "" || os.Getenv(EmbeddedEnv) != "" {
+		options = append(options, WithoutWatching())
+	} else {
+		options = append(options, Root{Path: overlay})
+	}
+	return New(options...)
+}
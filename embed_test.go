@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var embedded = fstest.MapFS{
+	"index.html":       {Data: []byte("embedded index")},
+	"about.html":       {Data: []byte("embedded about")},
+	"admin/users.html": {Data: []byte("embedded users")},
+}
+
+// startEmbedded starts a Reloader like NewEmbedded with the overlay.
+func startEmbedded(t *testing.T, overlay string, options ...Option) *Reloader {
+	t.Helper()
+	r := NewEmbedded(embedded, overlay, options...)
+	r.Debounce = testDelay
+	r.Coalesce = testDelay
+	r.Settle = testDelay
+	r.Scan()
+	ctx, cancel := context.WithCancel(context.Background())
+	r.Watch(ctx)
+	t.Cleanup(func() {
+		cancel()
+		r.Close()
+	})
+	return r
+}
+
+// captureStdout returns what f prints.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = pw
+	defer func() { os.Stdout = stdout }()
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(pr)
+		out <- string(b)
+	}()
+	f()
+	pw.Close()
+	return <-out
+}
+
+func TestEmbeddedWithOverlay(t *testing.T) {
+	overlay := t.TempDir()
+	writeFiles(t, overlay, map[string]string{"admin/users.html": "disk users"})
+	watchers := &fakeWatchers{}
+	r := startEmbedded(t, overlay, watchers.option())
+	for key, want := range map[string]string{
+		"index":       "embedded index",
+		"admin/users": "disk users",
+	} {
+		if out := execute(t, r, key, nil); out != want {
+			t.Errorf("%s = %q, want %q", key, out, want)
+		}
+	}
+
+	edit(t, overlay, watchers, map[string]string{"index.html": "disk index"})
+	if out := execute(t, r, "index", nil); out != "disk index" {
+		t.Errorf("index = %q once added to the overlay, want disk index", out)
+	}
+
+	path := filepath.Join(overlay, "admin", "users.html")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	since := currentVersion()
+	watchers.last().Send(path, fsnotify.Remove)
+	published(t, since)
+	if out := execute(t, r, "admin/users", nil); out != "embedded users" {
+		t.Errorf("admin/users = %q once removed from the overlay, want the embedded one", out)
+	}
+}
+
+func TestEmbeddedOnly(t *testing.T) {
+	overlay := t.TempDir()
+	writeFiles(t, overlay, map[string]string{"index.html": "disk index"})
+	t.Setenv(EmbeddedEnv, "1")
+	r := startEmbedded(t, overlay)
+	if _, ok := r.Watcher.(nopWatcher); !ok {
+		t.Errorf("watching with a %T, want none", r.Watcher)
+	}
+	if out := execute(t, r, "index", nil); out != "embedded index" {
+		t.Errorf("index = %q, want the embedded one", out)
+	}
+}
+
+func TestOverrideIsWarnedOnce(t *testing.T) {
+	overlay := t.TempDir()
+	writeFiles(t, overlay, map[string]string{"index.html": "disk index"})
+	r := startEmbedded(t, overlay, (&fakeWatchers{}).option())
+	out := captureStdout(t, func() {
+		r.Scan()
+		r.Scan()
+	})
+	if n := strings.Count(out, "Warning: template index added"); n != 0 {
+		t.Errorf("override warned %d more times:\n%s", n, out)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Template is a parsed template of either engine.
+type Template interface {
+	Name() string
+	Execute(w io.Writer, data interface{}) error
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+// Engine selects the template package a root's files are parsed with.
+type Engine int
+
+const (
+	// HTML parses with html/template, escaping output for HTML.
+	HTML Engine = iota
+	// Text parses with text/template, for emails, feeds and other
+	// non-HTML output.
+	Text
+	// Markdown converts Markdown to HTML, see WithMarkdown.
+	Markdown
+)
+
+func (e Engine) String() string {
+	switch e {
+	case Text:
+		return "text"
+	case Markdown:
+		return "markdown"
+	}
+	return "html"
+}
+
+// WithEngine parses files with the extensions exts with engine, whatever
+// the Engine of their root, like WithEngine(Text, ".txt", ".xml") for
+// emails and feeds that html/template would escape. The extensions are
+// added to those of template files in the roots not listing their own.
+func WithEngine(engine Engine, exts ...string) Option {
+	return optionFunc(func(r *Reloader) {
+		if r.engines == nil {
+			r.engines = map[string]Engine{}
+		}
+		for _, ext := range exts {
+			if ext = strings.TrimSpace(ext); ext != "" {
+				if !strings.HasPrefix(ext, ".") {
+					ext = "." + ext
+				}
+				r.engines[ext] = engine
+			}
+		}
+	})
+}
+
+// engineExts returns the extensions given to WithEngine, sorted.
+func (r *Reloader) engineExts() []string {
+	exts := make([]string, 0, len(r.engines))
+	for ext := range r.engines {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// WithContentType sends pages rendered from the template name with the
+// Content-Type contentType, overriding the one of their kind. name is a
+// template key, or an extension like ".xml" applying to every template
+// file with it. Keys take precedence over extensions.
+func WithContentType(name, contentType string) Option {
+	return optionFunc(func(r *Reloader) {
+		if r.contentTypes == nil {
+			r.contentTypes = map[string]string{}
+		}
+		r.contentTypes[name] = contentType
+	})
+}
+
+// contentType returns the Content-Type of pages rendered from the template
+// key: the one given to WithContentType for it or its extension, else HTML,
+// or for text/template files the type of their extension.
+func (r *Reloader) contentType(key string) string {
+	r.RLock()
+	path, ok := r.sources[key]
+	r.RUnlock()
+	if t, ok := r.contentTypes[key]; ok {
+		return t
+	}
+	if t, ok := r.contentTypes[filepath.Ext(path)]; ok {
+		return t
+	}
+	if !ok || r.engine(path) != Text {
+		return "text/html; charset=utf-8"
+	}
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		return t
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// parseSettings are applied to templates before they're parsed.
+type parseSettings struct {
+	// funcs are available to the templates.
+	funcs map[string]interface{}
+	// options are set as with template.Option.
+	options []string
+	// left and right delimit actions, "{{" and "}}" if empty.
+	left, right string
+	// preprocessors transform template files before they're parsed.
+	preprocessors []func(path string, src []byte) ([]byte, error)
+	// loader returns the loader of the root of a template file, and the
+	// file's name within it.
+	loader func(path string) (Loader, string)
+	// minify minifies HTML templates before they're parsed.
+	minify bool
+	// layout returns the layout of Markdown pages.
+	layout func() Template
+}
+
+// WithDelims makes templates delimit actions with left and right instead
+// of "{{" and "}}", like WithDelims("[[", "]]") for pages also processed by
+// a front-end framework using the default ones.
+func WithDelims(left, right string) Option {
+	return optionFunc(func(r *Reloader) { r.left, r.right = left, right })
+}
+
+// parseFiles parses the page together with partials with the engine,
+// naming the resulting template after the page. The page is parsed last,
+// so its definitions override those of the partials. Markdown pages have
+// no partials.
+func (e Engine) parseFiles(s parseSettings, page string, partials ...string) (Template, error) {
+	if e == Markdown {
+		partials = nil
+	}
+	files := append(partials[:len(partials):len(partials)], page)
+	return e.parse(s, filepath.Base(page), files...)
+}
+
+// parse parses files with the engine into a template called name.
+func (e Engine) parse(s parseSettings, name string, files ...string) (Template, error) {
+	if e == Markdown {
+		return parseMarkdown(s, name, files...)
+	}
+	srcs, err := e.read(s, files...)
+	if err != nil {
+		return nil, err
+	}
+	return e.parseSources(s, name, srcs...)
+}
+
+// source is the text of a template to parse and the name it's defined
+// under, like template.ParseFiles does, the base name of its file.
+type source struct {
+	name, text string
+}
+
+// read reads files as sources, passed through the preprocessors and
+// minified with WithMinify.
+func (e Engine) read(s parseSettings, files ...string) ([]source, error) {
+	srcs := make([]source, len(files))
+	for i, file := range files {
+		b, err := s.readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		srcs[i] = source{filepath.Base(file), e.minify(s, string(b))}
+	}
+	return srcs, nil
+}
+
+// WithPreprocessor transforms the source of template files after they're
+// read and before they're parsed, whenever they are, like to rewrite a
+// legacy include syntax. It's called with the file's path. An error fails
+// the template like a parse error would, so it keeps its last good
+// version. Preprocessors run in the order they were given.
+func WithPreprocessor(preprocess func(path string, src []byte) ([]byte, error)) Option {
+	return optionFunc(func(r *Reloader) {
+		r.preprocessors = append(r.preprocessors, preprocess)
+	})
+}
+
+// readFile reads the template file at path from the loader of its root,
+// through the preprocessors.
+func (s parseSettings) readFile(path string) ([]byte, error) {
+	var src []byte
+	var err error
+	if s.loader != nil {
+		l, name := s.loader(path)
+		src, err = l.Read(name)
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.preprocess(path, src)
+}
+
+// preprocess passes the source of the template at path through the
+// preprocessors.
+func (s parseSettings) preprocess(path string, src []byte) ([]byte, error) {
+	var err error
+	for _, preprocess := range s.preprocessors {
+		if src, err = preprocess(path, src); err != nil {
+			return nil, fmt.Errorf("preprocessing %s: %w", path, err)
+		}
+	}
+	return src, nil
+}
+
+// minify returns text minified if templates of the engine are, see
+// WithMinify.
+func (e Engine) minify(s parseSettings, text string) string {
+	if !s.minify || e != HTML {
+		return text
+	}
+	return minifyHTML(text, s.left, s.right)
+}
+
+// parseSources parses srcs with the engine into a template called name,
+// the source named name becoming its body and the others associated
+// templates, like template.ParseFiles.
+func (e Engine) parseSources(s parseSettings, name string, srcs ...source) (Template, error) {
+	if e == Text {
+		tmpl := texttemplate.New(name).Delims(s.left, s.right).
+			Funcs(s.funcs).Option(s.options...)
+		for _, src := range srcs {
+			t := tmpl
+			if src.name != name {
+				t = tmpl.New(src.name)
+			}
+			if _, err := t.Parse(src.text); err != nil {
+				return nil, err
+			}
+		}
+		return tmpl, nil
+	}
+
+	tmpl := htmltemplate.New(name).Delims(s.left, s.right).
+		Funcs(s.funcs).Option(s.options...)
+	for _, src := range srcs {
+		t := tmpl
+		if src.name != name {
+			t = tmpl.New(src.name)
+		}
+		if _, err := t.Parse(src.text); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
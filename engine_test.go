@@ -0,0 +1,99 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDelims(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"index.html": `<p v-if="{{ ok }}">[[.Name]]</p>`,
+	}, WithDelims("[[", "]]"), WithFuncs(map[string]interface{}{"upper": strings.ToUpper}))
+	data := map[string]string{"Name": "gopher"}
+	if out := execute(t, r, "index", data); out != `<p v-if="{{ ok }}">gopher</p>` {
+		t.Errorf("index = %q", out)
+	}
+
+	// Reloads use the delimiters too, along with the functions.
+	edit(t, dir, watchers, map[string]string{"index.html": `{{ count }} [[upper .Name]]`})
+	if out := execute(t, r, "index", data); out != "{{ count }} GOPHER" {
+		t.Errorf("index = %q once reloaded", out)
+	}
+}
+
+func TestDelimsArePerReloader(t *testing.T) {
+	newTestReloader(t, nil, WithDelims("[[", "]]"))
+	r, _, _ := newTestReloader(t, map[string]string{"index.html": "{{.}} [[x]]"})
+	if out := execute(t, r, "index", "y"); out != "y [[x]]" {
+		t.Errorf("index = %q, want the default delimiters", out)
+	}
+}
+
+func TestTextEngine(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"feed.xml":   "",
+		"index.html": "",
+	}, WithEngine(Text, ".txt", ".xml"))
+	edit(t, dir, watchers, map[string]string{
+		"welcome.txt": "{{.}}",
+		"feed.xml":    "<link>{{.}}</link>",
+		"index.html":  "<p>{{.}}</p>",
+	})
+	data := "/?a=1&b=<2>"
+	for key, want := range map[string]string{
+		"welcome": "/?a=1&b=<2>",
+		"feed":    "<link>/?a=1&b=<2></link>",
+		"index":   "<p>/?a=1&amp;b=&lt;2&gt;</p>",
+	} {
+		if out := execute(t, r, key, data); out != want {
+			t.Errorf("%s = %q, want %q", key, out, want)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"page.html": "<p>page</p>",
+		"feed.xml":  "<rss></rss>",
+		"news.xml":  "<rss></rss>",
+		"data.json": `{"a": 1}`,
+	}, WithEngine(Text, ".xml", ".json"), WithContentType("feed", "application/rss+xml"))
+	get := func(path string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d", path, w.Code)
+		}
+		return w.Header().Get("Content-Type")
+	}
+	for path, want := range map[string]string{
+		"/page": "text/html; charset=utf-8",
+		"/feed": "application/rss+xml",
+		"/news": mime.TypeByExtension(".xml"),
+		"/data": mime.TypeByExtension(".json"),
+	} {
+		if got := get(path); got != want {
+			t.Errorf("GET %s: Content-Type %q, want %q", path, got, want)
+		}
+	}
+
+	// It's still right once reloaded.
+	edit(t, dir, watchers, map[string]string{"feed.xml": "<rss><item/></rss>"})
+	if got := get("/feed"); got != "application/rss+xml" {
+		t.Errorf("GET /feed once edited: Content-Type %q", got)
+	}
+
+	// One set by the handler is kept.
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "application/atom+xml")
+	if err := r.RenderRequest(w, httptest.NewRequest(http.MethodGet, "/feed", nil), "feed", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/atom+xml" {
+		t.Errorf("Content-Type %q, want the handler's", got)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// WithErrorTemplate renders the template key in place of the built-in
+// response with the status, like "errors/404" for 404 Not Found or
+// "errors/500" for 500 Internal Server Error. It is executed with
+// ErrorData and reloads like any other template, but isn't served as a
+// page of its own. If it's missing or fails, a plain error is sent.
+func WithErrorTemplate(status int, key string) Option {
+	return optionFunc(func(r *Reloader) {
+		if r.errorTemplates == nil {
+			r.errorTemplates = map[int]string{}
+		}
+		r.errorTemplates[status] = key
+	})
+}
+
+// ErrorData is what error templates are executed with.
+type ErrorData struct {
+	Host       string
+	Status     int
+	StatusText string
+	// Key is the template that is missing or failed.
+	Key string
+	// Error is why it failed, only set in development.
+	Error string
+}
+
+// isErrorTemplate reports whether key is given to WithErrorTemplate.
+func (r *Reloader) isErrorTemplate(key string) bool {
+	for _, k := range r.errorTemplates {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// renderError responds with the error template for status, with err in
+// the data unless running with -prod. It reports false, responding
+// nothing, if no template is given for status.
+func (r *Reloader) renderError(w http.ResponseWriter, host string, status int, key string, err error) bool {
+	errorKey, ok := r.errorTemplates[status]
+	if !ok {
+		return false
+	}
+	data := ErrorData{
+		Host:       host,
+		Status:     status,
+		StatusText: http.StatusText(status),
+		Key:        key,
+	}
+	if err != nil && !*production {
+		data.Error = err.Error()
+	}
+
+	// The error template is executed directly rather than rendered like a
+	// page, so its own failure can't lead back here.
+	tmpl, err := r.Get(errorKey)
+	if err != nil {
+		fmt.Println("Error template", errorKey+":", err)
+		http.Error(w, http.StatusText(status), status)
+		return true
+	}
+	buf, err := r.buffers.execute(context.Background(), tmpl, data)
+	defer r.buffers.put(buf)
+	if err != nil {
+		fmt.Println("Error rendering", errorKey+":", err)
+		http.Error(w, http.StatusText(status), status)
+		return true
+	}
+	w.Header().Set("Content-Type", r.contentType(errorKey))
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+	return true
+}
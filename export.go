@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Export renders every page into dir the way they're served, as
+// dir/index.html for "index" and dir/about/index.html for "about", or with
+// their file's extension for pages that aren't HTML, like dir/feed.xml.
+// The static directories are copied to dir/static, and the error template
+// for 404, if there is one, to dir/404.html. Templates failing to parse or
+// render fail the export, which goes on to report all of them.
+func (r *Reloader) Export(dir string) error {
+	var failed []string
+	if err := r.Preload(); err != nil {
+		failed = append(failed, err.Error())
+	}
+
+	h := r.Handler()
+	get := func(path string) *exportResponse {
+		w := &exportResponse{header: http.Header{}, Code: http.StatusOK}
+		h.ServeHTTP(w, &http.Request{
+			Method:     http.MethodGet,
+			URL:        &url.URL{Path: path},
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{},
+			Host:       "example.com",
+			RemoteAddr: "192.0.2.1:1234",
+			RequestURI: path,
+		})
+		return w
+	}
+	exported := 0
+	for _, key := range r.Names() {
+		if r.isHidden(key) {
+			continue
+		}
+		w := get("/" + key)
+		if w.Code != http.StatusOK {
+			failed = append(failed, fmt.Sprintf("%s: %d %s", key, w.Code, http.StatusText(w.Code)))
+			continue
+		}
+		if err := writeExport(filepath.Join(dir, r.exportPath(key, w.Header().Get("Content-Type"))), &w.Body); err != nil {
+			return err
+		}
+		exported++
+	}
+	if _, ok := r.errorTemplates[http.StatusNotFound]; ok {
+		// Any path that isn't a page gets the 404 page.
+		w := get("/404.html")
+		if w.Code != http.StatusNotFound {
+			failed = append(failed, fmt.Sprintf("404 page: %d %s", w.Code, http.StatusText(w.Code)))
+		} else if err := writeExport(filepath.Join(dir, "404.html"), &w.Body); err != nil {
+			return err
+		}
+	}
+
+	// Earlier static directories take precedence, so they're copied last.
+	for i := len(r.static) - 1; i >= 0; i-- {
+		if err := copyTree(r.static[i], filepath.Join(dir, "static")); err != nil {
+			return err
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("export failed for %d templates:\n  %s",
+			len(failed), strings.Join(failed, "\n  "))
+	}
+	fmt.Printf("Exported %d pages to %s.\n", exported, dir)
+	return nil
+}
+
+// exportResponse records a page rendered for Export. It's what
+// httptest.ResponseRecorder would be, without linking the testing package
+// into the binary.
+type exportResponse struct {
+	header      http.Header
+	Code        int
+	Body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (w *exportResponse) Header() http.Header { return w.header }
+
+func (w *exportResponse) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.Code, w.wroteHeader = code, true
+	}
+}
+
+func (w *exportResponse) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.header.Get("Content-Type") == "" {
+			w.header.Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.Body.Write(p)
+}
+
+// exportPath returns the file the page key is exported to, relative to the
+// export directory.
+func (r *Reloader) exportPath(key, contentType string) string {
+	name := filepath.FromSlash(key)
+	if t, _, _ := mime.ParseMediaType(contentType); t != "text/html" {
+		r.RLock()
+		path := r.sources[key]
+		r.RUnlock()
+		return name + filepath.Ext(path)
+	}
+	if key == "index" {
+		return "index.html"
+	}
+	return filepath.Join(name, "index.html")
+}
+
+// writeExport writes the contents of src to path, creating its directory.
+func writeExport(path string, src io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// copyTree copies the files below src to the same places below dst.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return writeExport(filepath.Join(dst, rel), f)
+	})
+}
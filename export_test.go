@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	setFlag(t, production, true)
+	dir, static, out := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.html":  `{{template "_nav.html"}}<p>index</p>`,
+		"about.html":  "<p>about</p>",
+		"feed.xml":    "<feed/>",
+		"_nav.html":   "<nav></nav>",
+		"errors.html": "<p>not found</p>",
+	})
+	writeFiles(t, static, map[string]string{"css/app.css": "body {}"})
+	r := New(Root{Path: dir}, WithEngine(Text, ".xml"), WithoutWatching(),
+		WithErrorTemplate(404, "errors"))
+	defer r.Close()
+	if err := r.WatchStatic(static); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Export(out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"index.html":         "<nav></nav><p>index</p>",
+		"about/index.html":   "<p>about</p>",
+		"feed.xml":           "<feed/>",
+		"404.html":           "<p>not found</p>",
+		"static/css/app.css": "body {}",
+	}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s not exported: %v", name, err)
+		} else if string(got) != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+	for _, name := range []string{"_nav.html", "_nav/index.html", "errors/index.html"} {
+		if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(name))); err == nil {
+			t.Errorf("%s was exported", name)
+		}
+	}
+}
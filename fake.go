@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher reports changes to the files in the directories added to it.
+// The Reloader uses an fsnotify.Watcher unless given another one with
+// WithWatcher.
+type Watcher interface {
+	Events() <-chan fsnotify.Event
+	Errors() <-chan error
+	Add(name string) error
+	Remove(name string) error
+	Close() error
+}
+
+// WithWatcher makes the Reloader watch with the watchers newWatcher
+// returns, like a FakeWatcher in tests. A new one is asked for whenever
+// the watcher died and is recreated.
+func WithWatcher(newWatcher func() (Watcher, error)) Option {
+	return optionFunc(func(r *Reloader) { r.newWatcher = newWatcher })
+}
+
+// fsWatcher is a Watcher using fsnotify.
+type fsWatcher struct {
+	*fsnotify.Watcher
+}
+
+func newFSWatcher() (Watcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return fsWatcher{w}, nil
+}
+
+func (w fsWatcher) Events() <-chan fsnotify.Event { return w.Watcher.Events }
+
+func (w fsWatcher) Errors() <-chan error { return w.Watcher.Errors }
+
+// WithoutWatching keeps the Reloader from watching the roots, for one that
+// only serves what it loads, like embedded templates in production, or
+// loads them once, as -check and -export do.
+func WithoutWatching() Option {
+	return WithWatcher(func() (Watcher, error) { return nopWatcher{}, nil })
+}
+
+// nopWatcher is a Watcher watching nothing. It never reports anything, and
+// as its channels are never closed it's never taken for a dead one.
+type nopWatcher struct{}
+
+func (nopWatcher) Events() <-chan fsnotify.Event { return nil }
+
+func (nopWatcher) Errors() <-chan error { return nil }
+
+func (nopWatcher) Add(name string) error { return nil }
+
+func (nopWatcher) Remove(name string) error { return nil }
+
+func (nopWatcher) Close() error { return nil }
+
+// FakeWatcher is a Watcher reporting only the events and errors it's
+// given, so the Reloader can be driven without waiting on the filesystem.
+type FakeWatcher struct {
+	events chan fsnotify.Event
+	errors chan error
+
+	mu     sync.Mutex
+	dirs   map[string]bool
+	closed bool
+}
+
+// NewFakeWatcher returns a FakeWatcher watching nothing yet.
+func NewFakeWatcher() *FakeWatcher {
+	return &FakeWatcher{
+		events: make(chan fsnotify.Event),
+		errors: make(chan error),
+		dirs:   map[string]bool{},
+	}
+}
+
+// Send reports a change op to the file name, waiting for the Reloader to
+// take it.
+func (w *FakeWatcher) Send(name string, op fsnotify.Op) {
+	w.events <- fsnotify.Event{Name: name, Op: op}
+}
+
+// SendError reports err, waiting for the Reloader to take it.
+func (w *FakeWatcher) SendError(err error) {
+	w.errors <- err
+}
+
+// Watched reports whether dir was added and not removed since.
+func (w *FakeWatcher) Watched(dir string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dirs[filepath.Clean(dir)]
+}
+
+func (w *FakeWatcher) Events() <-chan fsnotify.Event { return w.events }
+
+func (w *FakeWatcher) Errors() <-chan error { return w.errors }
+
+func (w *FakeWatcher) Add(name string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errors.New("watcher closed")
+	}
+	// Like fsnotify, only what exists can be watched.
+	if _, err := os.Stat(name); err != nil {
+		return err
+	}
+	w.dirs[filepath.Clean(name)] = true
+	return nil
+}
+
+func (w *FakeWatcher) Remove(name string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.dirs, filepath.Clean(name))
+	return nil
+}
+
+// Close closes the channels, which the Reloader takes as the watcher
+// dying.
+func (w *FakeWatcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.closed = true
+		close(w.events)
+		close(w.errors)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestFakeWatcher(t *testing.T) {
+	w := NewFakeWatcher()
+	dir := t.TempDir()
+	if err := w.Add(dir + string(filepath.Separator)); err != nil {
+		t.Fatal(err)
+	}
+	if !w.Watched(dir) {
+		t.Errorf("%s isn't watched once added", dir)
+	}
+
+	go w.Send(filepath.Join(dir, "index.html"), fsnotify.Write)
+	if evt := <-w.Events(); evt.Name != filepath.Join(dir, "index.html") || evt.Op != fsnotify.Write {
+		t.Errorf("got %v, want the Write sent", evt)
+	}
+	go w.SendError(fsnotify.ErrEventOverflow)
+	if err := <-w.Errors(); err != fsnotify.ErrEventOverflow {
+		t.Errorf("got error %v, want the one sent", err)
+	}
+
+	if err := w.Remove(dir); err != nil || w.Watched(dir) {
+		t.Errorf("Remove = %v, and %s is still watched: %v", err, dir, w.Watched(dir))
+	}
+	w.Close()
+	w.Close()
+	if _, ok := <-w.Events(); ok {
+		t.Error("Events() isn't closed")
+	}
+	if _, ok := <-w.Errors(); ok {
+		t.Error("Errors() isn't closed")
+	}
+	if err := w.Add(dir); err == nil {
+		t.Error("Add succeeded once closed")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+)
+
+// sprigFuncs returns the sprig functions when built with the sprig tag,
+// see sprig.go, so binaries built without it don't include them.
+var sprigFuncs func() map[string]interface{}
+
+// WithSprig makes the sprig functions, like upper, default, ternary and
+// dict, available to every template. Functions given to WithFuncs and Funcs
+// take precedence over them. It needs a binary built with "-tags sprig".
+func WithSprig() Option {
+	return optionFunc(func(r *Reloader) {
+		if sprigFuncs == nil {
+			fmt.Println("Warning: built without the sprig tag; sprig functions are unavailable.")
+			return
+		}
+		r.sprig = sprigFuncs()
+	})
+}
+
+// WithFuncs makes the functions in funcs, like a template.FuncMap,
+// available to every template, and keeps them available when templates are
+// reparsed after a change.
+func WithFuncs(funcs map[string]interface{}) Option {
+	return optionFunc(func(r *Reloader) { r.addFuncs(funcs) })
+}
+
+// Funcs adds the functions in funcs to those available to every template,
+// from the next time templates are parsed. Call Refresh to have the
+// templates parsed already pick them up.
+func (r *Reloader) Funcs(funcs map[string]interface{}) {
+	r.Lock()
+	defer r.Unlock()
+	r.addFuncs(funcs)
+}
+
+func (r *Reloader) addFuncs(funcs map[string]interface{}) {
+	if r.userFuncs == nil {
+		r.userFuncs = map[string]interface{}{}
+	}
+	for name, fn := range funcs {
+		r.userFuncs[name] = fn
+	}
+}
+
+// funcs returns the functions available to every template. Those given to
+// WithFuncs and Funcs take precedence over the built-in ones, which take
+// precedence over sprig's.
+func (r *Reloader) funcs() map[string]interface{} {
+	funcs := map[string]interface{}{}
+	for name, fn := range r.sprig {
+		funcs[name] = fn
+	}
+	funcs["inline"] = r.inline
+	funcs["asset"] = r.assetURL
+	// Pages get livereload for their request, see ClientFuncs.
+	funcs["livereload"] = func() htmltemplate.HTML { return "" }
+	if l := r.locales; l != nil {
+		// Pages get T in the locale of the request, see LocaleFuncs.
+		funcs["T"] = func(key string, args ...interface{}) string {
+			return r.T(l.fallback, key, args...)
+		}
+	}
+	r.RLock()
+	defer r.RUnlock()
+	for name, fn := range r.userFuncs {
+		funcs[name] = fn
+	}
+	return funcs
+}
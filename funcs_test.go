@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFuncsSurviveReloads(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": `{{upper "one"}}`},
+		WithFuncs(map[string]interface{}{"upper": strings.ToUpper}))
+	if out := execute(t, r, "index", nil); out != "ONE" {
+		t.Errorf("index = %q, want ONE", out)
+	}
+	edit(t, dir, watchers, map[string]string{"index.html": `{{upper "two"}}`})
+	if out := execute(t, r, "index", nil); out != "TWO" {
+		t.Errorf("index = %q once reloaded, want TWO", out)
+	}
+
+	// Functions registered later are there for the next reload.
+	r.Funcs(map[string]interface{}{"repeat": strings.Repeat})
+	edit(t, dir, watchers, map[string]string{"index.html": `{{upper (repeat "a" 3)}}`})
+	if errs := r.ParseErrors(); len(errs) > 0 {
+		t.Fatalf("ParseErrors() = %v", errs)
+	}
+	if out := execute(t, r, "index", nil); out != "AAA" {
+		t.Errorf("index = %q, want AAA", out)
+	}
+}
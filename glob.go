@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// globSet is a template set parsed from every file matching its patterns.
+type globSet struct {
+	name     string
+	patterns []string
+}
+
+// WithGlobSet parses every template file in the roots matching one of
+// patterns into a single template stored under name, the way ParseGlob
+// does, like WithGlobSet("site", "templates/**/*.html"). Get(name) returns
+// it, for rendering its members with ExecuteTemplate. Patterns are matched
+// as for WithExclude, but against the whole path. The set is reparsed as a
+// whole whenever a matching file changes, keeping the previous one if that
+// fails.
+func WithGlobSet(name string, patterns ...string) Option {
+	return optionFunc(func(r *Reloader) {
+		r.globSets = append(r.globSets, globSet{name, patterns})
+	})
+}
+
+// matches reports whether the file name belongs to the set.
+func (g globSet) matches(name string) bool {
+	name = strings.TrimPrefix(slashed(filepath.Clean(name)), "/")
+	for _, pattern := range g.patterns {
+		pattern = strings.TrimPrefix(slashedPattern(filepath.Clean(pattern)), "/")
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// globFiles returns the template files in the roots belonging to the set.
+func (r *Reloader) globFiles(g globSet) []string {
+	_, dirs := walk(r.rootPaths(), r.walkOptions())
+	var files []string
+	for _, list := range dirs {
+		for _, f := range list {
+			if _, ok := r.templateKey(f.path); ok && g.matches(f.path) {
+				files = append(files, f.path)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// isGlobSet reports whether name is the name of a template set, which has
+// no page of its own to render.
+func (r *Reloader) isGlobSet(name string) bool {
+	for _, g := range r.globSets {
+		if g.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGlobSet parses the set g, keeping the previous template if that
+// fails.
+func (r *Reloader) parseGlobSet(g globSet) error {
+	files := r.globFiles(g)
+	if len(files) == 0 {
+		return fmt.Errorf("template set %s: no files match %v", g.name, g.patterns)
+	}
+	tmpl, err := r.engine(files[0]).parse(r.parseSettings(), g.name, files...)
+	if err != nil {
+		return fmt.Errorf("template set %s: %w", g.name, err)
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.sets == nil {
+		r.sets = map[string]Template{}
+	}
+	r.sets[g.name] = tmpl
+	return nil
+}
+
+// reloadGlobSets reparses the sets the changed file name belongs to.
+func (r *Reloader) reloadGlobSets(name string) error {
+	var firstErr error
+	for _, g := range r.globSets {
+		if !g.matches(name) {
+			continue
+		}
+		debugf("File: %s is in template set %s; reparsing it.\n", name, g.name)
+		if err := r.parseGlobSet(g); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
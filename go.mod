@@ -4,7 +4,29 @@ go 1.21.1
 
 require github.com/fsnotify/fsnotify v1.6.0
 
+require github.com/yuin/goldmark v1.7.8
+
+require go.uber.org/goleak v1.3.0
+
+require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/Masterminds/sprig/v3 v3.2.3
+)
+
+require (
+	github.com/Masterminds/goutils v1.1.1 // indirect
+	github.com/Masterminds/semver/v3 v3.2.0 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/huandu/xstrings v1.3.3 // indirect
+	github.com/imdario/mergo v0.3.12 // indirect
+	github.com/mitchellh/copystructure v1.2.0 // indirect
+	github.com/mitchellh/reflectwalk v1.0.2 // indirect
+	github.com/shopspring/decimal v1.2.0 // indirect
+	github.com/spf13/cast v1.4.1 // indirect
+	golang.org/x/crypto v0.14.0 // indirect
+)
+
 require (
 	github.com/gorilla/websocket v1.5.0 // direct
-	golang.org/x/sys v0.0.0-20220908164124-27713097b956 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// contentHash returns the SHA-256 of the file at path.
+func contentHash(path string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// remember records the contents of the file at path as handled.
+func (q *changeQueue) remember(path string) {
+	sum, err := contentHash(path)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err != nil {
+		delete(q.hashes, path)
+		return
+	}
+	if q.hashes == nil {
+		q.hashes = map[string][sha256.Size]byte{}
+	}
+	q.hashes[path] = sum
+}
+
+// unchanged reports whether the file at path holds the same bytes as when
+// it was last handled, like after a touch or a generator rewriting it,
+// recording its contents for next time otherwise. Removed or renamed files
+// always count as changed.
+func (q *changeQueue) unchanged(path string, op fsnotify.Op) bool {
+	if op.Has(fsnotify.Remove) || op.Has(fsnotify.Rename) {
+		q.remember(path)
+		return false
+	}
+	if !op.Has(fsnotify.Write) && !op.Has(fsnotify.Create) {
+		return false
+	}
+	sum, err := contentHash(path)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err != nil {
+		delete(q.hashes, path)
+		return false
+	}
+	last, ok := q.hashes[path]
+	if q.hashes == nil {
+		q.hashes = map[string][sha256.Size]byte{}
+	}
+	q.hashes[path] = sum
+	return ok && sum == last
+}
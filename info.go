@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+)
+
+// infoPath serves the Info document.
+const infoPath = "/_livereload/info"
+
+// Info describes what the server supports, so editor plugins and other
+// clients can discover it. It is built from the routes and message types
+// actually registered rather than maintained by hand.
+type Info struct {
+	Version  string `json:"version"`
+	Protocol int    `json:"protocol"`
+	// Transports maps each transport clients can connect with to its path.
+	Transports map[string]string `json:"transports"`
+	Events     []string          `json:"events"`
+	Endpoints  []string          `json:"endpoints"`
+	// Auth reports whether changes pushed to the webhook have to carry
+	// a secret.
+	Auth bool `json:"auth"`
+}
+
+// serverVersion returns the module version the binary was built from.
+func serverVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+func getServeInfo(info *Info) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	})
+}
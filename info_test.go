@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// getInfo returns the Info served by r.
+func getInfo(t *testing.T, r *Reloader) Info {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, infoPath, nil))
+	var info Info
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+// declaredMessageTypes returns the values of the Message constants
+// declared in protocol.go.
+func declaredMessageTypes(t *testing.T) []string {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), "protocol.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			for i, name := range value.Names {
+				if !strings.HasPrefix(name.Name, "Message") || i >= len(value.Values) {
+					continue
+				}
+				if lit, ok := value.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					s, _ := strconv.Unquote(lit.Value)
+					types = append(types, s)
+				}
+			}
+		}
+	}
+	return types
+}
+
+func TestInfoListsEveryMessageType(t *testing.T) {
+	r, _, _ := newTestReloader(t, nil)
+	info := getInfo(t, r)
+	declared := declaredMessageTypes(t)
+	if len(declared) == 0 {
+		t.Fatal("no Message constants found in protocol.go")
+	}
+	for _, typ := range declared {
+		if !slices.Contains(info.Events, typ) {
+			t.Errorf("message type %q is missing from %s events %v", typ, infoPath, info.Events)
+		}
+	}
+	if info.Transports["websocket"] != wsPath {
+		t.Errorf("websocket transport = %q, want %q", info.Transports["websocket"], wsPath)
+	}
+}
+
+func TestInfoAuth(t *testing.T) {
+	r, _, _ := newTestReloader(t, nil)
+	if getInfo(t, r).Auth {
+		t.Error("Auth = true without a webhook")
+	}
+	r.Webhook = NewWebhook("secret")
+	info := getInfo(t, r)
+	if !info.Auth {
+		t.Error("Auth = false with a webhook secret")
+	}
+	if !slices.Contains(info.Endpoints, hookPath) {
+		t.Errorf("endpoints %v don't list %s", info.Endpoints, hookPath)
+	}
+}
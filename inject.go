@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// closingBody is what the reload client is injected before.
+var closingBody = []byte("</body>")
+
+// openingSocket marks pages with a hand-rolled client opening a websocket
+// themselves, which get no second one.
+var openingSocket = []byte("new WebSocket(")
+
+// heldBack is how much of what's written is held back, as it may be the
+// start of closingBody or openingSocket split across writes.
+var heldBack = max(len(closingBody), len(openingSocket)) - 1
+
+// InjectClient wraps next so the HTML pages it responds with load the
+// reload client, injecting its tag before </body>, or at the end of pages
+// without one, so templates don't need to include it. Other responses,
+// errors, and pages loading the client themselves, like with
+// {{livereload}} or a script opening a WebSocket before </body>, are
+// passed through untouched. Pages are streamed as they're written, holding
+// back just enough to find </body> across writes. HEAD requests are
+// answered like GET ones without the body, so the Content-Length they
+// report includes the client.
+func (r *Reloader) InjectClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodHead {
+			hw := &headWriter{ResponseWriter: w}
+			defer hw.send()
+			w, req = hw, req.Clone(req.Context())
+			req.Method = http.MethodGet
+		}
+		iw := &injectingWriter{ResponseWriter: w, reloader: r, req: req}
+		next.ServeHTTP(iw, req)
+		iw.finish()
+	})
+}
+
+// headWriter answers a HEAD request with the headers of the GET response
+// written to it, counting the length of its body instead of sending it.
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	length int
+}
+
+func (w *headWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *headWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	w.length += len(p)
+	return len(p), nil
+}
+
+// Flush is a no-op, the headers are sent once the length is known.
+func (w *headWriter) Flush() {}
+
+// send sends the headers.
+func (w *headWriter) send() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status >= 200 && w.status != http.StatusNoContent && w.status != http.StatusNotModified {
+		w.Header().Set("Content-Length", strconv.Itoa(w.length))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// injectingWriter injects the reload client into the page written to it,
+// see InjectClient.
+type injectingWriter struct {
+	http.ResponseWriter
+	reloader *Reloader
+	req      *http.Request
+
+	// decided is set once the status is written, and inject if the page
+	// then gets the client.
+	decided, inject bool
+	// hasClient is set when the page loads the client itself.
+	hasClient bool
+	injected  bool
+	// held is the end of what was written, see heldBack.
+	held []byte
+}
+
+func (w *injectingWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *injectingWriter) WriteHeader(status int) {
+	if !w.decided {
+		w.decided = true
+		h := w.Header()
+		w.inject = !w.hasClient && status >= 200 && status < 300 &&
+			status != http.StatusNoContent && status != http.StatusPartialContent &&
+			isHTML(h.Get("Content-Type")) && h.Get("Content-Encoding") == ""
+		if w.inject {
+			// The tag makes the page longer.
+			h.Del("Content-Length")
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *injectingWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.inject || w.injected || w.hasClient {
+		if err := w.release(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	data := append(w.held, p...)
+	w.held = nil
+	i := indexFold(data, closingBody)
+	if j := bytes.Index(data, openingSocket); j >= 0 && (i < 0 || j < i) {
+		w.hasClient = true
+		if _, err := w.ResponseWriter.Write(data); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if i >= 0 {
+		w.injected = true
+		tag, err := w.reloader.clientSnippet(w.ResponseWriter, w.req)
+		if err != nil {
+			return 0, err
+		}
+		for _, b := range [][]byte{data[:i], []byte(tag), data[i:]} {
+			if _, err := w.ResponseWriter.Write(b); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	keep := min(len(data), heldBack)
+	if _, err := w.ResponseWriter.Write(data[:len(data)-keep]); err != nil {
+		return 0, err
+	}
+	w.held = append([]byte(nil), data[len(data)-keep:]...)
+	return len(p), nil
+}
+
+// Flush sends what was written so far, except what's held back.
+func (w *injectingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// release writes what was held back.
+func (w *injectingWriter) release() error {
+	if len(w.held) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.held)
+	w.held = nil
+	return err
+}
+
+// finish ends the page, appending the client if it had no </body>.
+func (w *injectingWriter) finish() {
+	if err := w.release(); err != nil || !w.inject || w.injected || w.hasClient {
+		return
+	}
+	if tag, err := w.reloader.clientSnippet(w.ResponseWriter, w.req); err == nil {
+		w.ResponseWriter.Write([]byte(tag))
+	}
+}
+
+// indexFold returns the index of the first instance of the lower case
+// sep in s, ignoring ASCII case, or -1. Unlike searching bytes.ToLower(s),
+// the index is into s itself: lowering characters like U+212A KELVIN SIGN
+// changes their length.
+func indexFold(s, sep []byte) int {
+	for i := 0; i+len(sep) <= len(s); i++ {
+		j := 0
+		for j < len(sep) && lowerASCII(s[i+j]) == sep[j] {
+			j++
+		}
+		if j == len(sep) {
+			return i
+		}
+	}
+	return -1
+}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func isHTML(contentType string) bool {
+	t, _, _ := mime.ParseMediaType(contentType)
+	return t == "text/html"
+}
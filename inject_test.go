@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// injected returns the status, headers and body of the response to a GET
+// of a server responding with handler wrapped in InjectClient.
+func injected(t *testing.T, handler http.HandlerFunc) (*http.Response, string) {
+	t.Helper()
+	r, _, _ := newTestReloader(t, nil)
+	srv := httptest.NewServer(r.InjectClient(handler))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+// respond returns a handler responding with body, typed contentType.
+func respond(status int, contentType, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+// clientBefore checks body is page with the client's script tag injected
+// before end, or at the end of the page if end is empty.
+func clientBefore(t *testing.T, body, page, end string) {
+	t.Helper()
+	i := len(page)
+	if end != "" {
+		i = strings.Index(page, end)
+	}
+	if n := strings.Count(body, "<script"); n != 1 {
+		t.Fatalf("page = %q, want the client injected once", body)
+	}
+	if body[:i] != page[:i] || !strings.HasSuffix(body, page[i:]) {
+		t.Errorf("page = %q, want the client injected at %d of %q", body, i, page)
+	}
+	if tag := body[i : len(body)-len(page[i:])]; !strings.HasPrefix(tag, "<script") {
+		t.Errorf("injected %q, want the client's script tag", tag)
+	}
+}
+
+func TestInjectClientIntoHTML(t *testing.T) {
+	tests := []struct {
+		name, page, end string
+	}{
+		{"lower case", "<html><body><p>hi</p></body></html>", "</body>"},
+		{"upper case", "<HTML><BODY><P>hi</P></BODY></HTML>", "</BODY>"},
+		// Lowering the Kelvin sign shortens it by a byte.
+		{"after a Kelvin sign", "<html><body><p>0 K</p></Body></html>", "</Body>"},
+		{"without </body>", "<p>hi</p>", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := injected(t, respond(http.StatusOK, "text/html; charset=utf-8", tt.page))
+			clientBefore(t, body, tt.page, tt.end)
+			if resp.ContentLength != -1 && resp.ContentLength != int64(len(body)) {
+				t.Errorf("Content-Length %d for a %d byte page", resp.ContentLength, len(body))
+			}
+		})
+	}
+}
+
+func TestInjectClientSkipsOtherResponses(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"JSON", http.StatusOK, "application/json", `{"html": "<body></body>"}`},
+		{"error page", http.StatusInternalServerError, "text/html", "<html><body>oops</body></html>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := injected(t, respond(tt.status, tt.contentType, tt.body))
+			if body != tt.body {
+				t.Errorf("body = %q, want %q untouched", body, tt.body)
+			}
+			if resp.ContentLength != int64(len(tt.body)) {
+				t.Errorf("Content-Length %d, want %d", resp.ContentLength, len(tt.body))
+			}
+		})
+	}
+}
+
+func TestInjectClientIntoChunkedHTML(t *testing.T) {
+	// </body> is split across writes, flushed as separate chunks.
+	chunks := []string{"<html><body>", "<p>streamed</p></bo", "dy></html>"}
+	page := strings.Join(chunks, "")
+	resp, body := injected(t, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		for _, chunk := range chunks {
+			io.WriteString(w, chunk)
+			w.(http.Flusher).Flush()
+		}
+	})
+	if len(resp.TransferEncoding) == 0 || resp.TransferEncoding[0] != "chunked" {
+		t.Errorf("Transfer-Encoding %v, want chunked", resp.TransferEncoding)
+	}
+	clientBefore(t, body, page, "</body>")
+}
+
+func TestInjectClientSkipsHandRolledClients(t *testing.T) {
+	// The page opens its own socket, split across writes like </body>.
+	chunks := []string{
+		`<html><body><script>var conn = new Web`,
+		`Socket("ws://" + location.host + "/ws");</script></body></html>`,
+	}
+	page := strings.Join(chunks, "")
+	_, body := injected(t, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		for _, chunk := range chunks {
+			io.WriteString(w, chunk)
+			w.(http.Flusher).Flush()
+		}
+	})
+	if body != page {
+		t.Errorf("page = %q, want %q untouched", body, page)
+	}
+}
+
+func TestServedPageGetsOneClient(t *testing.T) {
+	tests := map[string]string{
+		"livereload func": "<html><body>{{livereload}}</body></html>",
+		"injected":        "<html><body></body></html>",
+		"hand-rolled":     `<html><body><script>new WebSocket("ws://{{.Host}}/ws")</script></body></html>`,
+	}
+	for name, page := range tests {
+		s := NewTestServer(t)
+		s.WriteTemplate("index.html", page)
+		body := s.get("/")
+		if n := strings.Count(body, "<script"); n != 1 {
+			t.Errorf("%s: page = %q, want one client", name, body)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxInlineSize caps the size of files embedded with the inline function.
+const maxInlineSize = 64 << 10
+
+// assetCache holds the contents of inlined files and the hashes of static
+// files. Entries are dropped when the watcher reports a change, so renders
+// don't read the disk every time.
+type assetCache struct {
+	mu       sync.Mutex
+	contents map[string][]byte
+	hashes   map[string]string
+	// dirs are the directories of cached files, watched so their changes
+	// reload the page.
+	dirs map[string]bool
+}
+
+// inline returns the contents of the named file, looked up in the static
+// directories and then in the roots, for embedding it in the page with
+// {{inline "css/critical.css"}}. The optional kind ("css", "js", "svg" or
+// "html") says how the contents are escaped, and defaults to the file's
+// extension. Other files are escaped as text.
+func (r *Reloader) inline(name string, kind ...string) (interface{}, error) {
+	path, ok := r.StaticFile(name)
+	if !ok {
+		path, ok = r.rootFile(name)
+	}
+	if !ok {
+		return nil, fmt.Errorf("inline: %s not found", name)
+	}
+
+	src, err := r.asset(path)
+	if err != nil {
+		return nil, err
+	}
+
+	k := strings.TrimPrefix(filepath.Ext(path), ".")
+	if len(kind) > 0 {
+		k = kind[0]
+	}
+	switch k {
+	case "css":
+		return template.CSS(src), nil
+	case "js":
+		return template.JS(src), nil
+	case "svg", "html":
+		return template.HTML(src), nil
+	default:
+		return string(src), nil
+	}
+}
+
+// rootFile looks name up in the roots, last root first.
+func (r *Reloader) rootFile(name string) (string, bool) {
+	roots := r.rootList()
+	for i := len(roots) - 1; i >= 0; i-- {
+		path := filepath.Join(roots[i].Path,
+			filepath.Clean("/"+filepath.FromSlash(name)))
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// asset returns the contents of path from the cache, reading and watching
+// the file on a miss.
+func (r *Reloader) asset(path string) ([]byte, error) {
+	c := &r.assets
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if src, ok := c.contents[path]; ok {
+		return src, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxInlineSize {
+		return nil, fmt.Errorf("inline: %s is %d bytes, over the %d byte limit",
+			path, info.Size(), maxInlineSize)
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.contents == nil {
+		c.contents = map[string][]byte{}
+		c.dirs = map[string]bool{}
+	}
+	c.contents[path] = src
+	if dir := filepath.Dir(path); !c.dirs[dir] {
+		r.RLock()
+		watcher := r.Watcher
+		r.RUnlock()
+		if err := watcher.Add(dir); err != nil {
+			return nil, err
+		}
+		c.dirs[dir] = true
+	}
+	return src, nil
+}
+
+// invalidateAsset drops path from the cache.
+func (r *Reloader) invalidateAsset(path string) {
+	r.assets.mu.Lock()
+	delete(r.assets.contents, filepath.Clean(path))
+	delete(r.assets.hashes, filepath.Clean(path))
+	r.assets.mu.Unlock()
+}
+
+// isAsset reports whether name is an inlined file outside the roots, whose
+// changes only need to reload the page.
+func (r *Reloader) isAsset(name string) bool {
+	if _, _, ok := r.fileRoot(name); ok {
+		return false
+	}
+	r.assets.mu.Lock()
+	defer r.assets.mu.Unlock()
+	return r.assets.dirs[filepath.Dir(filepath.Clean(name))]
+}
+
+// assetDirs returns the directories watched for inlined files.
+func (r *Reloader) assetDirs() []string {
+	r.assets.mu.Lock()
+	defer r.assets.mu.Unlock()
+	dirs := make([]string, 0, len(r.assets.dirs))
+	for dir := range r.assets.dirs {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
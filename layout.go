@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// LayoutBlock is the template pages define to be placed in the layout, see
+// WithLayout.
+const LayoutBlock = "content"
+
+// WithLayout renders every page through the layout template key, like
+// "layout" for layout.html, which places the page where it executes
+// {{template "content" .}}. The layout is parsed into every page like a
+// partial, so editing it reparses them all, and isn't served on its own.
+// Pages that don't {{define "content"}} are rendered without it.
+func WithLayout(key string) Option {
+	return optionFunc(func(r *Reloader) {
+		r.pageLayout = strings.TrimSuffix(slashed(key), filepath.Ext(key))
+	})
+}
+
+// isLayout reports whether key is the layout given to WithLayout.
+func (r *Reloader) isLayout(key string) bool {
+	return r.pageLayout != "" && key == r.pageLayout
+}
+
+// withLayout returns the page parsed from path executing the layout, if
+// there is one parsed with the same engine and the page defines the
+// content block.
+func (r *Reloader) withLayout(path string, tmpl Template) Template {
+	if r.pageLayout == "" {
+		return tmpl
+	}
+	r.RLock()
+	layout, ok := r.partials[r.pageLayout]
+	r.RUnlock()
+	if !ok || r.engine(layout) != r.engine(path) {
+		return tmpl
+	}
+	for _, name := range defined(tmpl) {
+		if name == LayoutBlock {
+			return blockTemplate{tmpl, filepath.Base(layout)}
+		}
+	}
+	r.errors.printf("Warning: %s defines no %q block; rendering it without layout %s.\n",
+		path, LayoutBlock, r.pageLayout)
+	return tmpl
+}
@@ -0,0 +1,51 @@
+package main
+
+import "fmt"
+
+// load parses the template key from the file providing it when it wasn't
+// loaded yet, like a file created while the server was down. The file is
+// looked for without holding up the handling of changes, and keys without
+// one are remembered until files change. Parsing is serialized with the
+// handling of changes, so concurrent requests for the same key parse it
+// once and a load never races a reload of the same file.
+func (r *Reloader) load(key string) (Template, error) {
+	notFound := fmt.Errorf("%w: %s", ErrTemplateNotFound, key)
+	if r.isPartial(key) || r.isMissing(key) {
+		return nil, notFound
+	}
+	if err := r.parseError(key); err != nil {
+		return nil, &brokenTemplate{key, err}
+	}
+	gen := r.keysGen()
+	path, ok := r.resolve(key)
+	if !ok {
+		r.setMissing(key, gen)
+		return nil, notFound
+	}
+
+	r.changes.handling.Lock()
+	defer r.changes.handling.Unlock()
+	// Another request may have loaded it while this one waited.
+	if tmpl := r.lookup(key); tmpl != nil {
+		return tmpl, nil
+	}
+	if err := r.parseError(key); err != nil {
+		return nil, &brokenTemplate{key, err}
+	}
+	// Files changed meanwhile may provide the key from elsewhere.
+	if r.keysGen() != gen {
+		if path, ok = r.resolve(key); !ok {
+			return nil, notFound
+		}
+	}
+	tmpl, err := r.parsePage(path)
+	if err != nil {
+		err = r.broken(key, err)
+		r.errors.print(err)
+		return nil, err
+	}
+	r.store(key, path, tmpl)
+	r.changes.remember(path)
+	fmt.Printf("Template %s loaded from %s on first use.\n", key, path)
+	return tmpl, nil
+}
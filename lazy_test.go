@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestConcurrentLoadsParseOnce(t *testing.T) {
+	r, dir, _ := newTestReloader(t, nil)
+	// Written without an event, as while the server was down.
+	writeFiles(t, dir, map[string]string{"cold.html": "cold"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Get("cold"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := r.Version("cold"); v != 1 {
+		t.Errorf("Version(cold) = %d, want 1 from a single parse", v)
+	}
+}
+
+func TestMissesAreRemembered(t *testing.T) {
+	r, dir, _ := newTestReloader(t, nil)
+	if _, err := r.Get("late"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Fatalf("Get(late) = %v, want ErrTemplateNotFound", err)
+	}
+	writeFiles(t, dir, map[string]string{"late.html": "late"})
+	if _, err := r.Get("late"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Get(late) = %v before any change was reported, want ErrTemplateNotFound", err)
+	}
+	r.change(ChangeEvent{filepath.Join(dir, "late.html"), fsnotify.Create})
+	if out := execute(t, r, "late", nil); out != "late" {
+		t.Errorf("late = %q once its creation was reported", out)
+	}
+}
+
+func TestMissDoesntWaitForChanges(t *testing.T) {
+	r, _, _ := newTestReloader(t, nil)
+	r.changes.handling.Lock()
+	defer r.changes.handling.Unlock()
+	done := make(chan error)
+	go func() {
+		_, err := r.Get("missing")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("Get(missing) = %v, want ErrTemplateNotFound", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("Get(missing) waited for changes being handled")
+	}
+}
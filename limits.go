@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"syscall"
+)
+
+// watchError is the failure to watch one directory.
+type watchError struct {
+	path string
+	err  error
+}
+
+func (e *watchError) Error() string { return fmt.Sprintf("watch %s: %v", e.path, e.err) }
+
+func (e *watchError) Unwrap() error { return e.err }
+
+// isWatchLimit reports whether err says the system ran out of watches or
+// watcher file descriptors.
+func isWatchLimit(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE)
+}
+
+// watchTree watches dir and the directories below it with watcher. The
+// directories the system has no watches left for are polled instead, and
+// the other failures returned.
+func (r *Reloader) watchTree(watcher Watcher, dir string) error {
+	dirs, err := addTree(watcher, dir, r.walkOptions())
+	r.watching(true, dirs...)
+	if err == nil {
+		return nil
+	}
+
+	var limited []string
+	var errs []error
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var we *watchError
+		if errors.As(err, &we) && isWatchLimit(we.err) {
+			limited = append(limited, we.path)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+	if len(limited) > 0 {
+		r.pollUnwatched(limited)
+	}
+	return errors.Join(errs...)
+}
+
+// pollUnwatched polls dirs, which couldn't be watched for lack of watches.
+func (r *Reloader) pollUnwatched(dirs []string) {
+	// Directories below one being polled are covered by its poller.
+	sort.Strings(dirs)
+	var top []string
+	r.Lock()
+	if r.unwatched == nil {
+		r.unwatched = map[string]bool{}
+	}
+	for _, dir := range dirs {
+		if r.unwatched[dir] {
+			// Already polled since an earlier attempt.
+			continue
+		}
+		r.unwatched[dir] = true
+		if len(top) == 0 || !isWithin(dir, top[len(top)-1]) {
+			top = append(top, dir)
+		}
+	}
+	interval := r.pollInterval
+	r.Unlock()
+	if len(top) == 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	fmt.Printf("Unable to watch %d directories, the system is out of file "+
+		"watches: %v\n", len(dirs), top)
+	fmt.Println("Polling them instead. To watch them, raise the limits, " +
+		"e.g. with\n\tsudo sysctl fs.inotify.max_user_watches=524288 " +
+		"fs.inotify.max_user_instances=512")
+	r.AddSource(newPoller(interval, top, r.walkOptions()))
+}
+
+// watchCounts returns how many directories are watched, and how many are
+// polled because they couldn't be.
+func (r *Reloader) watchCounts() (watched, unwatched int) {
+	r.RLock()
+	defer r.RUnlock()
+	for _, ok := range r.dirs {
+		if ok {
+			watched++
+		}
+	}
+	return watched, len(r.unwatched)
+}
+
+// unwatchedDirs returns the directories polled because they couldn't be
+// watched.
+func (r *Reloader) unwatchedDirs() []string {
+	r.RLock()
+	defer r.RUnlock()
+	dirs := make([]string, 0, len(r.unwatched))
+	for dir := range r.unwatched {
+		dirs = append(dirs, filepath.Clean(dir))
+	}
+	sort.Strings(dirs)
+	return dirs
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"html/template"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -15,6 +22,10 @@ const (
 	// Poll file for changes with this period.
 	broadcastPeriod = 10 * time.Second
 
+	// writeWait bounds how long a write to a client may take, so one slow
+	// client can't hold up the broadcast to everyone else.
+	writeWait = 5 * time.Second
+
 	// TemplateExt is the extension for the physical template files. Failure
 	// to set this to the same extension your physical template files have
 	// will result in the failure to reload the files.
@@ -26,14 +37,106 @@ const (
 )
 
 var (
-	addr     = flag.String("addr", ":8080", "http service address")
+	addr    = flag.String("addr", ":8080", "http service address")
+	static  = flag.String("static", "", "directory of static assets served under /static/")
+	verbose = flag.Bool("v", false, "verbose logging")
+	partial = flag.String("partial-prefix", DefaultPartialPrefix,
+		"file name prefix of partial templates, empty to disable")
+	clientMode = flag.String("client", ClientAuto,
+		"how generated pages load the reload client: auto, external or inline")
+	confirm = flag.Bool("confirm", false,
+		"ask clients to acknowledge reloads and report those that don't")
+	ext = flag.String("ext", TemplateExt,
+		"comma separated extensions of template files")
+	markdown = flag.Bool("markdown", false,
+		"serve .md files as pages converted to HTML")
+	markdownLayout = flag.String("markdown-layout", "",
+		"template key of the layout Markdown pages are placed in as {{.Content}}")
+	minify = flag.Bool("minify", false,
+		"minify HTML templates before parsing them, to preview a minified production build")
+	textExt = flag.String("text-ext", "",
+		"comma separated extensions of template files parsed with text/template, like .txt,.xml")
+	delims = flag.String("delims", "",
+		"action delimiters separated by a space, like \"[[ ]]\"; {{ }} if empty")
+	partialDirs = flag.String("partial-dirs", "",
+		"comma separated directories below the roots holding only partials, like layouts,partials")
+	ignore = flag.String("ignore", strings.Join(DefaultIgnore, ","),
+		"comma separated file name patterns whose changes are ignored")
+	exclude = flag.String("exclude", "",
+		"comma separated glob patterns of paths, relative to their root, to neither watch nor parse")
+	follow = flag.Bool("follow-symlinks", false,
+		"watch and load templates in symlinked directories below the roots")
+	maxDepth = flag.Int("max-depth", 0,
+		"how many levels of directories below each root to watch; 0 for all, -1 for none")
+	inject = flag.Bool("inject", true,
+		"inject the reload client into pages that don't load it themselves")
+	clientPath = flag.String("client-path", DefaultClientPath,
+		"path the reload client script is served at")
+	clientSrc = flag.String("client-src", "",
+		"serve the reload client from this file instead of the built-in one")
+	targeted = flag.Bool("targeted", false,
+		"only reload pages known to use the changed template")
+	production = flag.Bool("prod", false,
+		"production mode: never show template sources in error pages")
+	poll = flag.Bool("poll", false,
+		"poll the directories for changes instead of relying on filesystem notifications, "+
+			"for Docker volumes and network filesystems")
+	hybrid = flag.Bool("hybrid", false,
+		"rely on filesystem notifications, but poll the directories they don't work for")
+	pollInterval = flag.Duration("poll-interval", DefaultPollInterval,
+		"how often -poll and -hybrid walk the directories")
+	hookSecret = flag.String("hook-secret", "",
+		"accept change notifications at "+hookPath+" carrying this secret")
+	templateOptions = flag.String("template-options", "",
+		"comma separated options set on every template, like missingkey=error")
+	strict = flag.Bool("strict", false,
+		"fail on missing map keys and dry run changed pages with their last data; ignored with -prod")
+	debounce = flag.Duration("debounce", DefaultDebounce,
+		"how long a file has to stay quiet before its changes are handled")
+	coalesce = flag.Duration("coalesce", DefaultCoalesce,
+		"how long changed files are collected to be reloaded together")
+	slowParse = flag.Duration("slow-parse", DefaultSlowParse,
+		"warn about templates taking longer than this to parse")
+	parseWorkers = flag.Int("parse-workers", 0,
+		"how many templates are parsed at once; GOMAXPROCS if 0")
+	renderTimeout = flag.Duration("render-timeout", 0,
+		"how long rendering a page may take before 503 Service Unavailable is returned; unlimited if 0")
+	settle = flag.Duration("settle", DefaultSettle,
+		"how often changed files are checked for still being written before parsing")
+	record = flag.String("record", "",
+		"append every filesystem event to this file as JSON lines")
+	replay = flag.String("replay", "",
+		"replay the events recorded in this file by -record")
+	replaySpeed = flag.Float64("replay-speed", 1,
+		"how many times faster than recorded -replay runs; 0 for no delay")
+	replayStub = flag.Bool("replay-stub", false,
+		"with -replay, print the files that would be parsed rather than reading them, to replay without the recorded templates")
+	play = flag.Bool("playground", false,
+		"serve "+playgroundPath+"{template} to render templates with JSON data")
+	errorTemplates = flag.String("error-templates", "",
+		"comma-separated status=key pairs of templates rendered for errors, like 404=errors/404")
+	contentTypes = flag.String("content-types", "",
+		"comma-separated key=type or .ext=type pairs overriding the Content-Type of pages")
+	localesDir = flag.String("locales", "",
+		"directory of JSON or TOML translation files, like en.json, for the T function")
+	localeFallback = flag.String("locale-fallback", "en",
+		"locale of translations missing from the one requested")
+	useSprig = flag.Bool("sprig", false,
+		"make the sprig functions available to templates; needs a build with -tags sprig")
+	exportDir = flag.String("export", "",
+		"render every page and copy the static directories into this directory, then exit")
+	checkOnly = flag.Bool("check", false,
+		"parse every template, report the failures and exit, non-zero if any failed")
+	checkJSON = flag.Bool("json", false,
+		"with -check, print the report as JSON")
 	upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
 	}
 	broadcastCondMu sync.Mutex
-	broadcastCond   *sync.Cond
-	versionCounter  uint64
+	broadcastCond   = sync.NewCond(&broadcastCondMu)
+	versionCounter  Version
+	lastMessage     Message
 )
 
 func handleWebSocket(w http.ResponseWriter, r *http.Request) *websocket.Conn {
@@ -46,64 +149,262 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) *websocket.Conn {
 	return conn
 }
 
-func waitForBroadcast(conn *websocket.Conn) {
-	// Wait for a broadcast signal
+// waitForBroadcast sends conn every message published after the version
+// seen, pinging it instead when woken without one, until writing fails or
+// the Reloader is closed.
+func waitForBroadcast(reloader *Reloader, conn *websocket.Conn, seen Version) {
 	broadcastCond.L.Lock()
-	var oldVersion uint64
+	defer broadcastCond.L.Unlock()
 	for {
-		oldVersion = versionCounter
-		broadcastCond.Wait()
+		if !seen.Before(versionCounter) && !reloader.isClosed() {
+			broadcastCond.Wait()
+		}
 
-		if oldVersion == versionCounter {
+		if reloader.isClosed() {
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server closed"),
+				time.Now().Add(writeWait))
+			conn.Close()
+			return
+		}
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if !seen.Before(versionCounter) {
 			// check if connection is still alive
 			if err := conn.WriteMessage(websocket.PingMessage, []byte{}); err != nil {
-				fmt.Errorf("<Websocket %v> Error writing: %v",
+				fmt.Printf("<Websocket %v> Error writing: %v\n",
 					conn.RemoteAddr(), err)
-				break
+				return
 			}
 			continue
 		}
 
-		err := conn.WriteJSON(websocketEvent{Type: "build_complete"})
+		seen = versionCounter
+		err := conn.WriteJSON(lastMessage)
 		if err != nil {
-			fmt.Errorf("<Websocket %v> Error writing: %v",
+			fmt.Printf("<Websocket %v> Error writing: %v\n",
 				conn.RemoteAddr(), err)
-			break
+			return
 		}
 	}
-	broadcastCond.L.Unlock()
 }
 
-type websocketEvent struct {
-	Type string `json:"type"`
+// currentVersion returns the version of the last message published.
+func currentVersion() Version {
+	broadcastCond.L.Lock()
+	defer broadcastCond.L.Unlock()
+	return versionCounter
 }
 
-func getServeWs() http.HandlerFunc {
+// publish stores msg as the latest message, bumps the version counter and
+// wakes every connected client so the message gets sent out.
+func publish(msg Message) {
+	broadcastCond.L.Lock()
+	versionCounter = versionCounter.Next()
+	msg.V = ProtocolVersion
+	msg.Version = versionCounter
+	if *confirm {
+		msg.Ack = true
+		deliveries.track(msg.Version)
+	}
+	lastMessage = msg
+	broadcastCond.L.Unlock()
+	broadcastCond.Broadcast()
+}
+
+func getServeWs(reloader *Reloader) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The version is taken before upgrading, so anything published
+		// once the client sees the connection open reaches it. A client
+		// reconnecting with the version it last got is sent the latest
+		// message if it missed any since.
+		seen := currentVersion()
+		v, err := strconv.ParseUint(r.URL.Query().Get(VersionParam), 10, 32)
+		if err == nil && Version(v).Before(seen) {
+			seen = Version(v)
+		}
+
 		var conn *websocket.Conn
 		if conn = handleWebSocket(w, r); conn == nil {
 			fmt.Println("Error handling websocket")
 			return
 		}
-		go waitForBroadcast(conn)
+		go readPump(conn)
+		go waitForBroadcast(reloader, conn, seen)
 	})
 }
 
-func getServeHome(reloader *Reloader) http.HandlerFunc {
+// isHidden reports whether the template name is never served as a page,
+// like partials.
+func (r *Reloader) isHidden(name string) bool {
+	return r.isPartial(name) || name == DiagnosticKey || r.isGlobSet(name) ||
+		r.isErrorTemplate(name)
+}
+
+// getServePage renders the template matching the request path, with "/"
+// serving "index". Partials are never served.
+func getServePage(reloader *Reloader) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.Error(w, "Not found", http.StatusNotFound)
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if name == "" {
+			name = "index"
+		}
+		tmpl, err := reloader.Get(name)
+		missing := errors.Is(err, ErrTemplateNotFound)
+		placeholder := name == "index" && missing
+		if !placeholder && reloader.isHidden(name) {
+			if !reloader.renderError(w, r.Host, http.StatusNotFound, name, nil) {
+				http.Error(w, "Not found", http.StatusNotFound)
+			}
 			return
 		}
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		if !placeholder && missing {
+			renderNotFound(reloader, w, r.Host, name)
 			return
 		}
 
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if placeholder {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			renderPlaceholder(reloader, w, r.Host)
+			return
+		}
 
+		if err := reloader.parseError(name); err != nil && !*production {
+			w.Header().Set("X-Livereload-Error",
+				strings.Join(strings.Fields(err.Error()), " "))
+		}
 		data := getData(r.Host)
-		render(reloader, w, "index", data)
+		// Pages like Markdown ones call no functions.
+		var funcs map[string]interface{}
+		if err == nil && takesFuncs(tmpl) {
+			funcs = reloader.pageFuncs(w, r)
+		}
+		if err := render(reloader, w, r, name, data, funcs, ""); err == nil {
+			reloader.recordUsage(r.URL.Path, name)
+		}
+	})
+}
+
+// getServeFavicon serves favicon.ico from the static directories, or an empty
+// response so browsers stop asking.
+func getServeFavicon(reloader *Reloader) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path, ok := reloader.StaticFile("favicon.ico"); ok {
+			http.ServeFile(w, r, path)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// getServeImplicit serves files browsers and crawlers request on their own,
+// like /robots.txt, from the static directories. Misses are only logged in
+// verbose mode since nobody asked for them explicitly.
+func getServeImplicit(reloader *Reloader) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path, ok := reloader.StaticFile(r.URL.Path); ok {
+			http.ServeFile(w, r, path)
+			return
+		}
+		debugf("Not found: %s\n", r.URL.Path)
+		http.NotFound(w, r)
+	})
+}
+
+func getServeStats(reloader *Reloader) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reloader.Stats())
+	})
+}
+
+// getServeTemplates lists the file providing each template key.
+func getServeTemplates(reloader *Reloader) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reloader.Sources())
+	})
+}
+
+// getServeStatic serves files from the static directories under /static/.
+func getServeStatic(reloader *Reloader) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, ok := reloader.StaticFile(strings.TrimPrefix(r.URL.Path, "/static/"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, path)
+	})
+}
+
+// Handler returns a handler serving the pages, the websocket and the
+// support endpoints, so the server can be run without main, for example
+// from an httptest.Server.
+func (r *Reloader) Handler() http.Handler {
+	mux := http.NewServeMux()
+	info := &Info{
+		Version:    serverVersion(),
+		Protocol:   ProtocolVersion,
+		Transports: map[string]string{},
+		Events:     messageTypes,
+	}
+	// Routes answer GET and HEAD unless they list their methods.
+	handle := func(path string, h http.Handler, methods ...string) {
+		if len(methods) == 0 {
+			methods = []string{http.MethodGet, http.MethodHead}
+		}
+		mux.Handle(path, allowMethods(h, methods...))
+		info.Endpoints = append(info.Endpoints, path)
+	}
+
+	var page http.Handler = getServePage(r)
+	if *inject && !*production {
+		page = r.InjectClient(page)
+	}
+	handle("/", serveClientOr(r, page))
+	handle("/static/", getServeStatic(r))
+	handle("/favicon.ico", getServeFavicon(r))
+	handle("/robots.txt", getServeImplicit(r))
+	handle("/.well-known/", getServeImplicit(r))
+	handle(wsPath, getServeWs(r), http.MethodGet)
+	info.Transports["websocket"] = wsPath
+	handle(*clientPath, getServeClient(r))
+	handle("/_livereload/stats", getServeStats(r))
+	handle("/_livereload/templates", getServeTemplates(r))
+	handle("/_livereload/pages", getServeUsage(r))
+	if r.Webhook != nil {
+		handle(hookPath, r.Webhook, http.MethodPost)
+		info.Auth = r.Webhook.secret != ""
+	}
+	if *play && !*production {
+		handle(playgroundPath, getServePlayground(r),
+			http.MethodGet, http.MethodHead, http.MethodPost)
+	}
+	handle(infoPath, getServeInfo(info))
+	return mux
+}
+
+// allowMethods answers requests with other methods than the given ones with
+// 405 Method Not Allowed.
+func allowMethods(h http.Handler, methods ...string) http.Handler {
+	allow := strings.Join(methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, m := range methods {
+			if r.Method == m {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		w.Header().Set("Allow", allow)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	})
+}
+
+// getServeUsage lists the template keys each recently requested path used.
+func getServeUsage(reloader *Reloader) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reloader.Usage())
 	})
 }
 
@@ -118,19 +419,170 @@ func broadcastInterval() {
 	}
 }
 
+// reparseOnHangup rereads every template whenever the process receives
+// SIGHUP, as with "kill -HUP", without dropping the connected clients.
+func reparseOnHangup(r *Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		fmt.Println("SIGHUP received; reparsing every template.")
+		r.Reparse()
+	}
+}
+
 func main() {
-	broadcastCond = sync.NewCond(&broadcastCondMu)
+	flag.Parse()
 	go broadcastInterval()
 
-	r := New("./")
-	r.templates = map[string]*template.Template{
-		"index": template.Must(template.ParseFiles("index.html")),
+	// Template directories are given as arguments, later ones overriding
+	// earlier ones.
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = []string{TemplatePath}
+	}
+	var options []Option
+	for _, dir := range dirs {
+		options = append(options, Root{Path: dir, MaxDepth: *maxDepth})
+	}
+	if *checkOnly || *exportDir != "" {
+		options = append(options, WithoutWatching())
+	} else if *poll {
+		options = append(options, WithPolling(*pollInterval))
+	} else if *hybrid {
+		options = append(options, WithHybrid(*pollInterval))
+	}
+	options = append(options, WithExtensions(strings.Split(*ext, ",")...))
+	if *markdown || *markdownLayout != "" {
+		options = append(options, WithMarkdown(*markdownLayout))
+	}
+	if *templateOptions != "" {
+		options = append(options, WithTemplateOptions(strings.Split(*templateOptions, ",")...))
+	}
+	if *minify {
+		options = append(options, WithMinify())
+	}
+	if *textExt != "" {
+		options = append(options, WithEngine(Text, strings.Split(*textExt, ",")...))
+	}
+	if left, right, ok := strings.Cut(strings.TrimSpace(*delims), " "); ok {
+		options = append(options, WithDelims(left, strings.TrimSpace(right)))
+	}
+	if *follow {
+		options = append(options, WithFollowSymlinks())
+	}
+	if *exclude != "" {
+		options = append(options, WithExclude(strings.Split(*exclude, ",")...))
+	}
+	for _, pair := range strings.Split(*errorTemplates, ",") {
+		status, key, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		code, err := strconv.Atoi(status)
+		if !ok || err != nil {
+			if pair != "" {
+				fmt.Println("Ignoring error template", pair)
+			}
+			continue
+		}
+		options = append(options, WithErrorTemplate(code, key))
+	}
+	for _, pair := range strings.Split(*contentTypes, ",") {
+		if name, contentType, ok := strings.Cut(strings.TrimSpace(pair), "="); ok {
+			options = append(options, WithContentType(name, contentType))
+		}
+	}
+	if *useSprig {
+		options = append(options, WithSprig())
+	}
+	if *localesDir != "" {
+		options = append(options, WithLocales(*localesDir, *localeFallback))
+	}
+	if *exportDir != "" {
+		// Exported pages are published, so they're rendered as in
+		// production, without the reload client.
+		*production = true
+	}
+	if *checkOnly || *exportDir != "" {
+		options = append(options, WithWatcher(func() (Watcher, error) {
+			return NewFakeWatcher(), nil
+		}))
+	}
+	if *record != "" {
+		f, err := os.OpenFile(*record, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			fmt.Println("Unable to record events:", err)
+		} else {
+			defer f.Close()
+			options = append(options, WithRecording(f))
+		}
+	}
+	if *replay != "" && *replayStub {
+		options = append(options, WithParseHook(func(name string) error {
+			fmt.Println("Replay: parsing", name)
+			return nil
+		}))
+	}
+	r := New(options...)
+	r.PartialPrefix = *partial
+	for _, dir := range strings.Split(*partialDirs, ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			r.PartialDirs = append(r.PartialDirs, dir)
+		}
+	}
+	r.Debounce = *debounce
+	r.Coalesce = *coalesce
+	r.Settle = *settle
+	r.RenderTimeout = *renderTimeout
+	r.ParseWorkers = *parseWorkers
+	r.SlowParse = *slowParse
+	r.Ignore = nil
+	for _, pattern := range strings.Split(*ignore, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			r.Ignore = append(r.Ignore, pattern)
+		}
+	}
+	if *checkOnly {
+		ok, err := check(r, os.Stdout, os.Stderr, *checkJSON)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Unable to write the report:", err)
+		}
+		if !ok || err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+	if *exportDir != "" {
+		if *static != "" {
+			if err := r.WatchStatic(*static); err != nil {
+				fmt.Println("Unable to find static directory:", err)
+			}
+		}
+		if err := r.Export(*exportDir); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *static != "" {
+		if err := r.WatchStatic(*static); err != nil {
+			fmt.Println("Unable to watch static directory:", err)
+		}
+	}
+	r.Scan()
+	if len(r.pages()) == 0 {
+		fmt.Printf("No %v templates found in %v; waiting for some to appear.\n",
+			r.exts(), r.rootPaths())
+	}
+	r.Watch(context.Background())
+	go reparseOnHangup(r)
+	if *hookSecret != "" {
+		r.Webhook = NewWebhook(*hookSecret)
+		r.AddSource(r.Webhook)
+	}
+	if *replay != "" {
+		if err := replayFile(r, *replay, *replaySpeed); err != nil {
+			fmt.Println("Unable to replay events:", err)
+		}
 	}
-	r.Watch()
-
-	http.Handle("/", getServeHome(r))
-	http.Handle("/ws", getServeWs())
 
 	fmt.Println("Listening to changes at ", *addr)
-	http.ListenAndServe(*addr, nil)
+	http.ListenAndServe(*addr, r.Handler())
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// liveignoreName is the file in a root listing paths to leave alone, in
+// gitignore syntax: one pattern per line, "#" comments, "!" negating an
+// earlier pattern, a trailing "/" matching only directories and a pattern
+// containing "/" being relative to the root rather than matching at any
+// depth.
+//
+// Exclude patterns are checked first and win: a path they exclude can't be
+// brought back with "!". Within the file the last matching pattern decides,
+// so "build/" followed by "!build/keep.html" keeps just that file.
+const liveignoreName = ".liveignore"
+
+type ignoreRule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+	// anchored patterns are matched against the whole path relative to
+	// the root instead of each name in it.
+	anchored bool
+}
+
+// ignoreFile holds the rules of a .liveignore file, in order.
+type ignoreFile []ignoreRule
+
+func parseIgnore(data string) ignoreFile {
+	var rules ignoreFile
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		rule.anchored = strings.Contains(line, "/")
+		rule.pattern = strings.TrimPrefix(line, "/")
+		if rule.pattern != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// excludes reports whether the rules leave out the slash separated path
+// rel, a directory if isDir.
+func (f ignoreFile) excludes(rel string, isDir bool) bool {
+	elems := strings.Split(rel, "/")
+	excluded := false
+	for _, rule := range f {
+		for i := range elems {
+			dir := i < len(elems)-1 || isDir
+			if rule.dirOnly && !dir {
+				continue
+			}
+			var ok bool
+			if rule.anchored {
+				ok = matchGlob(rule.pattern, strings.Join(elems[:i+1], "/"))
+			} else {
+				ok, _ = path.Match(rule.pattern, elems[i])
+			}
+			if ok {
+				excluded = !rule.negate
+				break
+			}
+		}
+	}
+	return excluded
+}
+
+// hasNegations reports whether a rule brings paths back, in which case no
+// directory can be skipped as a whole.
+func (f ignoreFile) hasNegations() bool {
+	for _, rule := range f {
+		if rule.negate {
+			return true
+		}
+	}
+	return false
+}
+
+// loadIgnore reads the .liveignore file of root, if there is one.
+func (r *Reloader) loadIgnore(root string) {
+	data, err := os.ReadFile(filepath.Join(root, liveignoreName))
+	if err != nil && !os.IsNotExist(err) {
+		r.errors.print(err)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if r.liveignore == nil {
+		r.liveignore = map[string]ignoreFile{}
+	}
+	r.liveignore[root] = parseIgnore(string(data))
+}
+
+// isLiveignored reports whether the .liveignore file of name's root leaves
+// it out.
+func (r *Reloader) isLiveignored(name string) bool {
+	fileRoot, _, ok := r.fileRoot(name)
+	if !ok {
+		return false
+	}
+	root := fileRoot.Path
+	r.RLock()
+	rules := r.liveignore[root]
+	r.RUnlock()
+	if len(rules) == 0 {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, name)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(name)
+	isDir := err == nil && info.IsDir()
+	if isDir && rules.hasNegations() {
+		return false
+	}
+	return rules.excludes(slashed(rel), isDir)
+}
+
+// reloadIgnore rereads the changed .liveignore file name, drops the
+// templates it now leaves out and loads and watches those it no longer
+// does.
+func (r *Reloader) reloadIgnore(name string) {
+	fileRoot, _, ok := r.fileRoot(name)
+	if !ok {
+		return
+	}
+	root := fileRoot.Path
+	fmt.Printf("%s changed; reloading ignore patterns.\n", name)
+	r.loadIgnore(root)
+
+	r.RLock()
+	watcher := r.Watcher
+	r.RUnlock()
+	if err := r.watchTree(watcher, root); err != nil {
+		r.errors.print(err)
+	}
+
+	r.Scan()
+	publish(Message{Type: MessageReload, Files: []string{name}})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+const testLiveignore = `# build output, except what's kept
+build/
+*.bak
+!build/keep.html
+`
+
+func TestIgnoreFileExcludes(t *testing.T) {
+	rules := parseIgnore(testLiveignore)
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"build", true, true},
+		{"build/index.html", false, true},
+		{"build/keep.html", false, false},
+		{"index.html.bak", false, true},
+		{"admin/users.html.bak", false, true},
+		{"index.html", false, false},
+		// "build/" only matches directories.
+		{"src/build", false, false},
+	}
+	for _, tt := range tests {
+		if got := rules.excludes(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("excludes(%q, %v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestLiveignore(t *testing.T) {
+	r, dir, _ := newTestReloader(t, map[string]string{
+		liveignoreName:     testLiveignore,
+		"index.html":       "index",
+		"build/index.html": "built",
+		"build/keep.html":  "kept",
+		"old.html.bak":     "old",
+	})
+	for _, key := range []string{"index", "build/keep"} {
+		if _, err := r.Get(key); err != nil {
+			t.Errorf("Get(%q): %v", key, err)
+		}
+	}
+	if _, err := r.Get("build/index"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Get(build/index) = %v, want ErrTemplateNotFound", err)
+	}
+	if !r.isIgnored(filepath.Join(dir, "old.html.bak")) {
+		t.Error("old.html.bak isn't ignored")
+	}
+}
+
+func TestExcludeWinsOverLiveignore(t *testing.T) {
+	r, _, _ := newTestReloader(t, map[string]string{
+		liveignoreName:    testLiveignore,
+		"build/keep.html": "kept",
+	}, WithExclude("build/**"))
+	if _, err := r.Get("build/keep"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Get(build/keep) = %v, want ErrTemplateNotFound as it's excluded", err)
+	}
+}
+
+func TestLiveignoreReloads(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"index.html":        "index",
+		"drafts/post.html":  "draft",
+		"drafts/other.html": "draft",
+	})
+	if _, err := r.Get("drafts/post"); err != nil {
+		t.Fatal(err)
+	}
+
+	edit(t, dir, watchers, map[string]string{liveignoreName: "drafts/\n"})
+	if _, err := r.Get("drafts/post"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Get(drafts/post) = %v once ignored, want ErrTemplateNotFound", err)
+	}
+
+	edit(t, dir, watchers, map[string]string{liveignoreName: "drafts/\n!drafts/post.html\n"})
+	if _, err := r.Get("drafts/post"); err != nil {
+		t.Errorf("Get(drafts/post) once brought back: %v", err)
+	}
+}
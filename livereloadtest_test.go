@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTimeout = 2 * time.Second
+
+// get returns the body of the page at path.
+func (s *TestServer) get(path string) string {
+	s.t.Helper()
+	_, body := s.fetch(path)
+	return body
+}
+
+// fetch returns the status and body of the response to a GET of path.
+func (s *TestServer) fetch(path string) (int, string) {
+	s.t.Helper()
+	resp, err := http.Get(s.Server.URL + path)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestReloadOnWrite(t *testing.T) {
+	s := NewTestServer(t)
+	s.WriteTemplate("index.html", "<p>one</p>")
+	msg := s.ExpectReload(testTimeout)
+	if want := filepath.Join(s.Dir, "index.html"); len(msg.Files) != 1 || msg.Files[0] != want {
+		t.Errorf("Files = %v, want [%s]", msg.Files, want)
+	}
+	if body := s.get("/"); !strings.Contains(body, "one") {
+		t.Errorf("page = %q, want it to contain one", body)
+	}
+
+	s.WriteTemplate("index.html", "<p>two</p>")
+	msg = s.ExpectReload(testTimeout)
+	if v := msg.Templates["index"]; v != 2 {
+		t.Errorf("Templates[index] = %d, want 2", v)
+	}
+	if body := s.get("/"); !strings.Contains(body, "two") {
+		t.Errorf("page = %q, want it to contain two", body)
+	}
+}
+
+func TestIgnoredFileDoesNotReload(t *testing.T) {
+	s := NewTestServer(t)
+	s.WriteTemplate("index.html.swp", "swap")
+	s.WriteTemplate("main.go", "package main")
+	s.ExpectNothing(200 * time.Millisecond)
+}
+
+func TestBadTemplateSendsError(t *testing.T) {
+	s := NewTestServer(t)
+	s.WriteTemplate("index.html", "<p>good</p>")
+	s.ExpectReload(testTimeout)
+
+	s.WriteTemplate("index.html", "<p>{{.Broken</p>")
+	msg := s.ExpectError(testTimeout)
+	if !strings.Contains(msg.Error, "index.html") {
+		t.Errorf("Error = %q, want it to name index.html", msg.Error)
+	}
+	if err := s.ParseErrors()["index"]; err == nil {
+		t.Error("ParseErrors()[index] = nil, want the parse error")
+	}
+}
+
+func TestReconnectCatchesUp(t *testing.T) {
+	s := NewTestServer(t)
+	c := s.Client()
+	s.WriteTemplate("index.html", "<p>one</p>")
+	s.ExpectReload(testTimeout)
+	c.ExpectReload(testTimeout)
+
+	// The reload while c is away is sent once it's back.
+	c.Close()
+	s.WriteTemplate("index.html", "<p>two</p>")
+	want := s.ExpectReload(testTimeout)
+	c.Reconnect()
+	if msg := c.ExpectReload(testTimeout); msg.Version != want.Version {
+		t.Errorf("caught up with version %d, want %d", msg.Version, want.Version)
+	}
+
+	// A client that's up to date isn't sent it again.
+	c.Reconnect()
+	c.ExpectNothing(100 * time.Millisecond)
+}
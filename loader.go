@@ -0,0 +1,343 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Loader provides the templates of a root, like from a database edited
+// through an admin UI or a bucket, see Root.Loader. Names are slash
+// separated paths, like "admin/users.html", and are turned into keys the
+// way files in a root are, with its Prefix and Ext and WithKeyFunc.
+// Templates of loaders are like those added with AddTemplate: a file
+// providing the same key takes precedence.
+type Loader interface {
+	// List returns the names of all templates and partials.
+	List() ([]string, error)
+	// Read returns the source of the template name, an error wrapping
+	// fs.ErrNotExist if it's gone.
+	Read(name string) ([]byte, error)
+	// Changes reports the names of templates that were edited, added or
+	// removed. It may return nil if the loader never reports any.
+	Changes() <-chan string
+}
+
+// WithLoader loads templates from loader too, on every Scan, and reloads
+// those it reports changed while the Reloader is watching. It's the root
+// Root{Loader: loader}. A loader that is an io.Closer is closed once the
+// Reloader stops.
+func WithLoader(loader Loader) Option {
+	return Root{Loader: loader}
+}
+
+// loaderState is the root of a loader and what it provided as of its last
+// load.
+type loaderState struct {
+	Loader
+	root Root
+	// keys maps the key of each template to its name.
+	keys map[string]string
+}
+
+// loaderKey returns the key of the template name of the loader l, and false
+// if name is not a template.
+func (r *Reloader) loaderKey(l *loaderState, name string) (string, bool) {
+	return r.rootKey(l.root, name)
+}
+
+// loadAll parses every template of the loader l, dropping those it no
+// longer lists, and returns the errors of those that failed to parse.
+func (r *Reloader) loadAll(l *loaderState) []error {
+	names, err := l.List()
+	if err != nil {
+		return []error{fmt.Errorf("listing templates: %w", err)}
+	}
+	sort.Strings(names)
+
+	var partials []source
+	pages := map[string]string{}
+	var errs []error
+	for _, name := range names {
+		key, ok := r.loaderKey(l, name)
+		switch {
+		case !ok:
+		case r.isPartial(key):
+			src, err := r.readLoaded(l, name)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			partials = append(partials, src)
+		default:
+			pages[key] = name
+		}
+	}
+
+	for key, name := range pages {
+		if err := r.loadPage(l, key, name, partials); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for key := range l.keys {
+		if _, ok := pages[key]; !ok {
+			r.Lock()
+			delete(r.added, key)
+			r.Unlock()
+		}
+	}
+	l.keys = pages
+	return errs
+}
+
+// loadPage parses the template name of the loader l under key, together
+// with the partials.
+func (r *Reloader) loadPage(l *loaderState, key, name string, partials []source) error {
+	src, err := r.readLoaded(l, name)
+	if err != nil {
+		return err
+	}
+	engine := r.rootEngine(l.root, name)
+	if engine == Markdown {
+		return fmt.Errorf("%s: loaders can't provide Markdown pages", name)
+	}
+	tmpl, err := engine.parseSources(r.parseSettings(), src.name, append(partials, src)...)
+	if err != nil {
+		return err
+	}
+	r.AddTemplate(key, tmpl)
+	return nil
+}
+
+// readLoaded reads the template name of the loader l as a source, passed
+// through the preprocessors and minified with WithMinify.
+func (r *Reloader) readLoaded(l *loaderState, name string) (source, error) {
+	b, err := l.Read(name)
+	if err != nil {
+		return source{}, err
+	}
+	s := r.parseSettings()
+	if b, err = s.preprocess(name, b); err != nil {
+		return source{}, err
+	}
+	return source{filepath.Base(name), r.rootEngine(l.root, name).minify(s, string(b))}, nil
+}
+
+// loadLoaders loads the templates of every loader.
+func (r *Reloader) loadLoaders() []error {
+	var errs []error
+	for _, l := range r.loaders {
+		errs = append(errs, r.loadAll(l)...)
+	}
+	return errs
+}
+
+// watchLoader reloads the templates the loader l reports changed, until
+// the Reloader stops.
+func (r *Reloader) watchLoader(l *loaderState) {
+	changes := l.Changes()
+	if changes == nil {
+		return
+	}
+	for {
+		select {
+		case name, ok := <-changes:
+			if !ok {
+				return
+			}
+			r.loaderChanged(l, name)
+		case <-r.stopped():
+			if c, ok := l.Loader.(io.Closer); ok {
+				c.Close()
+			}
+			return
+		}
+	}
+}
+
+// loaderChanged reloads the template name of the loader l and tells the
+// clients.
+func (r *Reloader) loaderChanged(l *loaderState, name string) {
+	key, ok := r.loaderKey(l, name)
+	if !ok {
+		return
+	}
+	r.changes.handling.Lock()
+	defer r.changes.handling.Unlock()
+	fmt.Printf("Template: %s Event: changed in loader. Hot reloading.\n", name)
+
+	var errs []error
+	if _, known := l.keys[key]; known && !r.isPartial(key) {
+		// An edited page is the only one to change, unless it's gone.
+		err := r.loadPage(l, key, name, r.loaderPartials(l))
+		if errors.Is(err, fs.ErrNotExist) {
+			errs = r.loadAll(l)
+		} else if err != nil {
+			errs = []error{err}
+		}
+	} else {
+		errs = r.loadAll(l)
+	}
+	for _, err := range errs {
+		r.errors.print(err)
+	}
+	if len(errs) > 0 {
+		publish(Message{Type: MessageError, Error: errs[0].Error()})
+		return
+	}
+	publish(Message{Type: MessageReload})
+}
+
+// loaderPartials reads the partials of the loader l.
+func (r *Reloader) loaderPartials(l *loaderState) []source {
+	names, err := l.List()
+	if err != nil {
+		r.errors.print(err)
+		return nil
+	}
+	sort.Strings(names)
+	var partials []source
+	for _, name := range names {
+		if key, ok := r.loaderKey(l, name); ok && r.isPartial(key) {
+			if src, err := r.readLoaded(l, name); err == nil {
+				partials = append(partials, src)
+			}
+		}
+	}
+	return partials
+}
+
+// FileLoader is a Loader of the files below Dir. The files of roots are
+// read through one. It reports no changes; wrap it with NewPollingLoader
+// for that, or add Dir as a root instead.
+type FileLoader struct {
+	Dir string
+}
+
+// fileLoader returns the FileLoader of the root of the file at path and
+// the file's name within it. Files outside the roots are read as they are.
+func (r *Reloader) fileLoader(path string) (Loader, string) {
+	root, _, ok := r.fileRoot(path)
+	if !ok {
+		return FileLoader{}, filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(root.dir(), path)
+	if err != nil {
+		return FileLoader{}, filepath.ToSlash(path)
+	}
+	return FileLoader{Dir: root.dir()}, filepath.ToSlash(rel)
+}
+
+func (l FileLoader) List() ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(l.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(l.Dir, path)
+		if err == nil {
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	return names, err
+}
+
+func (l FileLoader) Read(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(l.Dir, filepath.FromSlash(name)))
+}
+
+func (l FileLoader) Changes() <-chan string { return nil }
+
+// PollingLoader reports the changes of a Loader that can't report them
+// itself, by listing and reading its templates every interval and
+// comparing their contents to those of the last time.
+type PollingLoader struct {
+	Loader
+	changes chan string
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewPollingLoader returns a PollingLoader of l, polling every interval
+// until it's closed.
+func NewPollingLoader(l Loader, interval time.Duration) *PollingLoader {
+	p := &PollingLoader{
+		Loader:  l,
+		changes: make(chan string),
+		done:    make(chan struct{}),
+	}
+	// What's compared against is taken now rather than once polling
+	// starts, so changes made meanwhile aren't missed.
+	last, _ := p.sums()
+	go p.poll(interval, last)
+	return p
+}
+
+func (p *PollingLoader) Changes() <-chan string { return p.changes }
+
+// Close stops polling.
+func (p *PollingLoader) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+func (p *PollingLoader) poll(interval time.Duration, last map[string][sha256.Size]byte) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer close(p.changes)
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+		sums, ok := p.sums()
+		if !ok {
+			continue
+		}
+		var changed []string
+		for name, sum := range sums {
+			if old, ok := last[name]; !ok || old != sum {
+				changed = append(changed, name)
+			}
+		}
+		for name := range last {
+			if _, ok := sums[name]; !ok {
+				changed = append(changed, name)
+			}
+		}
+		sort.Strings(changed)
+		for _, name := range changed {
+			select {
+			case p.changes <- name:
+			case <-p.done:
+				return
+			}
+		}
+		last = sums
+	}
+}
+
+// sums returns the hash of the contents of every template, and false if
+// they can't be listed.
+func (p *PollingLoader) sums() (map[string][sha256.Size]byte, bool) {
+	names, err := p.List()
+	if err != nil {
+		return nil, false
+	}
+	sums := make(map[string][sha256.Size]byte, len(names))
+	for _, name := range names {
+		if b, err := p.Read(name); err == nil {
+			sums[name] = sha256.Sum256(b)
+		}
+	}
+	return sums, true
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// memLoader is a Loader of templates kept in memory, reporting changes
+// made with set unless quiet.
+type memLoader struct {
+	mu      sync.Mutex
+	files   map[string]string
+	changes chan string
+	quiet   bool
+}
+
+func newMemLoader(files map[string]string) *memLoader {
+	return &memLoader{files: files, changes: make(chan string, 10)}
+}
+
+func (l *memLoader) List() ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var names []string
+	for name := range l.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (l *memLoader) Read(name string) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	src, ok := l.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+	}
+	return []byte(src), nil
+}
+
+func (l *memLoader) Changes() <-chan string {
+	if l.quiet {
+		return nil
+	}
+	return l.changes
+}
+
+// set changes the template name, removing it if src is empty.
+func (l *memLoader) set(name, src string) {
+	l.mu.Lock()
+	if src == "" {
+		delete(l.files, name)
+	} else {
+		l.files[name] = src
+	}
+	l.mu.Unlock()
+	if !l.quiet {
+		l.changes <- name
+	}
+}
+
+func TestLoader(t *testing.T) {
+	l := newMemLoader(map[string]string{
+		"index.html": `{{template "_nav.html"}} index`,
+		"_nav.html":  "nav",
+	})
+	r, _ := startTestReloader(t, WithLoader(l))
+	if out := execute(t, r, "index", nil); out != "nav index" {
+		t.Errorf("index = %q", out)
+	}
+
+	since := currentVersion()
+	l.set("index.html", `{{template "_nav.html"}} edited`)
+	if msg := published(t, since); msg.Type != MessageReload {
+		t.Errorf("got %s message, want %s", msg.Type, MessageReload)
+	}
+	if out := execute(t, r, "index", nil); out != "nav edited" {
+		t.Errorf("index = %q once edited", out)
+	}
+
+	since = currentVersion()
+	l.set("_nav.html", "menu")
+	published(t, since)
+	if out := execute(t, r, "index", nil); out != "menu edited" {
+		t.Errorf("index = %q once the partial changed", out)
+	}
+
+	since = currentVersion()
+	l.set("about.html", "about")
+	published(t, since)
+	if out := execute(t, r, "about", nil); out != "about" {
+		t.Errorf("about = %q once added", out)
+	}
+	since = currentVersion()
+	l.set("about.html", "")
+	published(t, since)
+	if _, err := r.Get("about"); err == nil {
+		t.Error("about is still there once removed")
+	}
+}
+
+func TestLoaderKeysAreRootKeys(t *testing.T) {
+	files := map[string]string{"pages/Admin/Users.html": "users"}
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	r, _ := startTestReloader(t,
+		Root{Path: dir, Prefix: "disk/"},
+		Root{Loader: newMemLoader(files), Prefix: "db/"},
+		WithKeyFunc(lowerKey))
+	for _, key := range []string{"disk/admin/users", "db/admin/users"} {
+		if out := execute(t, r, key, nil); out != "users" {
+			t.Errorf("%s = %q, want users", key, out)
+		}
+	}
+}
+
+func TestPollingLoader(t *testing.T) {
+	l := newMemLoader(map[string]string{"index.html": "one"})
+	l.quiet = true
+	p := NewPollingLoader(l, testPollInterval)
+	r, _ := startTestReloader(t, WithLoader(p))
+	since := currentVersion()
+	l.set("index.html", "two")
+	published(t, since)
+	if out := execute(t, r, "index", nil); out != "two" {
+		t.Errorf("index = %q, want two", out)
+	}
+}
+
+func TestRootsReadThroughFileLoader(t *testing.T) {
+	r, dir, _ := newTestReloader(t, map[string]string{"admin/users.html": "users"})
+	l, name := r.fileLoader(filepath.Join(dir, "admin", "users.html"))
+	if l != (FileLoader{Dir: dir}) || name != "admin/users.html" {
+		t.Errorf("fileLoader() = %v, %q, want the root's FileLoader and admin/users.html", l, name)
+	}
+	if src, err := l.Read(name); err != nil || string(src) != "users" {
+		t.Errorf("Read(%q) = %q, %v", name, src, err)
+	}
+}
+
+func TestLoaderWhilePolling(t *testing.T) {
+	l := newMemLoader(map[string]string{"index.html": "one"})
+	r, _ := startTestReloader(t, WithLoader(l), WithPolling(testPollInterval))
+	since := currentVersion()
+	l.set("index.html", "two")
+	published(t, since)
+	if out := execute(t, r, "index", nil); out != "two" {
+		t.Errorf("index = %q, want two", out)
+	}
+}
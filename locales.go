@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+// LocaleParam is the query parameter choosing the locale of a page, over
+// the request's Accept-Language.
+const LocaleParam = "lang"
+
+// WithLocales loads translations for the T function from the JSON and TOML
+// files in dir, one per locale named like "en.json" or "pt-BR.toml".
+// Nested objects are flattened into keys like "checkout.title". The files
+// are watched and reloaded like templates, reloading every page. Keys
+// missing from a locale are taken from fallback.
+func WithLocales(dir, fallback string) Option {
+	return optionFunc(func(r *Reloader) {
+		r.locales = &locales{dir: filepath.Clean(dir), fallback: fallback}
+	})
+}
+
+// locales holds the translations of each locale, see WithLocales.
+type locales struct {
+	dir      string
+	fallback string
+
+	mu   sync.RWMutex
+	msgs map[string]map[string]string
+}
+
+// isLocale reports whether name is a locale file.
+func (r *Reloader) isLocale(name string) bool {
+	return r.locales != nil && filepath.Dir(filepath.Clean(name)) == r.locales.dir
+}
+
+// loadLocales reads every locale file, returning the errors of those that
+// failed to. A locale failing to load keeps its previous translations.
+func (r *Reloader) loadLocales() []error {
+	l := r.locales
+	if l == nil {
+		return nil
+	}
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return []error{&ChangeError{Path: l.dir, Err: err}}
+	}
+
+	l.mu.RLock()
+	old := l.msgs
+	l.mu.RUnlock()
+	loaded := map[string]map[string]string{}
+	var errs []error
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".json" && ext != ".toml") {
+			continue
+		}
+		path := filepath.Join(l.dir, entry.Name())
+		lang := strings.TrimSuffix(entry.Name(), ext)
+		msgs, err := readLocale(path)
+		if err != nil {
+			errs = append(errs, &ChangeError{Path: path, Err: err})
+			if prev, ok := old[lang]; ok {
+				loaded[lang] = prev
+			}
+			continue
+		}
+		loaded[lang] = msgs
+	}
+
+	l.mu.Lock()
+	l.msgs = loaded
+	l.mu.Unlock()
+	return errs
+}
+
+// readLocale reads the translations in the JSON or TOML file at path.
+func readLocale(path string) (map[string]string, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	if filepath.Ext(path) == ".toml" {
+		err = toml.Unmarshal(src, &tree)
+	} else {
+		err = json.Unmarshal(src, &tree)
+	}
+	if err != nil {
+		return nil, err
+	}
+	msgs := map[string]string{}
+	flatten(msgs, "", tree)
+	return msgs, nil
+}
+
+// flatten adds the values in tree to msgs, keyed by their path joined with
+// dots after prefix.
+func flatten(msgs map[string]string, prefix string, tree map[string]interface{}) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if sub, ok := value.(map[string]interface{}); ok {
+			flatten(msgs, key, sub)
+			continue
+		}
+		msgs[key] = fmt.Sprint(value)
+	}
+}
+
+// T returns the translation of key into the locale lang, formatted with
+// args like fmt.Sprintf if there are any. A locale like "pt-BR" falls back
+// to "pt", and then to the fallback given to WithLocales. Missing keys
+// show up as "¡key!" in development and as the key itself with -prod.
+func (r *Reloader) T(lang, key string, args ...interface{}) string {
+	msg, ok := r.translation(lang, key)
+	if !ok {
+		if *production {
+			return key
+		}
+		return "¡" + key + "!"
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+func (r *Reloader) translation(lang, key string) (string, bool) {
+	l := r.locales
+	if l == nil {
+		return "", false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	base, _, _ := strings.Cut(lang, "-")
+	for _, lang := range []string{lang, base, l.fallback} {
+		if msg, ok := l.msgs[lang][key]; ok {
+			return msg, true
+		}
+	}
+	return "", false
+}
+
+// hasLocale reports whether there are translations into lang.
+func (r *Reloader) hasLocale(lang string) bool {
+	l := r.locales
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.msgs[lang]
+	return ok
+}
+
+// Locale returns the locale to render the page requested with req in: the
+// one in its LocaleParam, else the first in its Accept-Language there are
+// translations into, else the fallback given to WithLocales.
+func (r *Reloader) Locale(req *http.Request) string {
+	if r.locales == nil {
+		return ""
+	}
+	if lang := req.URL.Query().Get(LocaleParam); lang != "" {
+		return lang
+	}
+	for _, tag := range strings.Split(req.Header.Get("Accept-Language"), ",") {
+		lang, _, _ := strings.Cut(strings.TrimSpace(tag), ";")
+		base, _, _ := strings.Cut(lang, "-")
+		switch {
+		case lang == "" || lang == "*":
+		case r.hasLocale(lang):
+			return lang
+		case r.hasLocale(base):
+			return base
+		}
+	}
+	return r.locales.fallback
+}
+
+// LocaleFuncs returns the request funcs translating into the locale of
+// req, for RenderRequest. Pages served by the Reloader get them already.
+func (r *Reloader) LocaleFuncs(req *http.Request) map[string]interface{} {
+	if r.locales == nil {
+		return nil
+	}
+	lang := r.Locale(req)
+	return map[string]interface{}{
+		"T": func(key string, args ...interface{}) string {
+			return r.T(lang, key, args...)
+		},
+	}
+}
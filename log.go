@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// repeatWindow is how long repeats of an error are collapsed into one line.
+const repeatWindow = 5 * time.Second
+
+// errorBuffer is how many errors ErrorLog holds for a slow receiver.
+// Errors beyond it are dropped and counted in Stats.ErrorsDropped.
+const errorBuffer = 64
+
+// ChangeError is an error handling the change Op to the file at Path, or
+// an error of the watcher itself if Path is empty.
+type ChangeError struct {
+	Path string
+	Op   fsnotify.Op
+	Err  error
+}
+
+func (e *ChangeError) Error() string {
+	switch {
+	case e.Path == "":
+		return "Watcher error: " + e.Err.Error()
+	case e.Op == 0:
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.Path, e.Op, e.Err)
+}
+
+func (e *ChangeError) Unwrap() error { return e.Err }
+
+// debugf prints only when verbose logging is enabled.
+func debugf(format string, args ...interface{}) {
+	if *verbose {
+		fmt.Printf(format, args...)
+	}
+}
+
+// errorLog prints errors, collapsing an error repeated within repeatWindow
+// into a single line followed by a repeat count, so a build touching
+// hundreds of files doesn't flood the terminal.
+type errorLog struct {
+	mu sync.Mutex
+	// out is where errors and warnings are printed, os.Stdout if nil.
+	out     io.Writer
+	repeats map[string]int
+	// errs receives every error once ErrorLog was called.
+	errs    chan error
+	dropped uint64
+}
+
+// ErrorLog returns a channel receiving the errors the Reloader runs into
+// from now on, like a *ChangeError for a change that failed. They're
+// printed all the same, so nothing needs to receive from it.
+func (r *Reloader) ErrorLog() <-chan error {
+	l := &r.errors
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.errs == nil {
+		l.errs = make(chan error, errorBuffer)
+	}
+	return l.errs
+}
+
+func (l *errorLog) print(err error) {
+	msg := err.Error()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.errs != nil {
+		select {
+		case l.errs <- err:
+		default:
+			atomic.AddUint64(&l.dropped, 1)
+		}
+	}
+	if _, seen := l.repeats[msg]; seen {
+		l.repeats[msg]++
+		return
+	}
+	if l.repeats == nil {
+		l.repeats = map[string]int{}
+	}
+	l.repeats[msg] = 0
+	fmt.Fprintln(l.writer(), msg)
+
+	time.AfterFunc(repeatWindow, func() {
+		l.mu.Lock()
+		n := l.repeats[msg]
+		delete(l.repeats, msg)
+		l.mu.Unlock()
+		if n > 0 {
+			fmt.Fprintf(l.writer(), "%s (repeated %d more times)\n", msg, n)
+		}
+	})
+}
+
+// printf prints a warning or note about the templates where errors are
+// printed.
+func (l *errorLog) printf(format string, args ...interface{}) {
+	fmt.Fprintf(l.writer(), format, args...)
+}
+
+// writer returns where errors are printed.
+func (l *errorLog) writer() io.Writer {
+	if l.out == nil {
+		return os.Stdout
+	}
+	return l.out
+}
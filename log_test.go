@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadNonTemplateIsNotAnError(t *testing.T) {
+	r, dir, _ := newTestReloader(t, map[string]string{"style.css": "body {}"})
+	if err := r.reload(filepath.Join(dir, "style.css")); err != nil {
+		t.Errorf("reloading a stylesheet: %v", err)
+	}
+}
+
+func TestReloadParseFailureIsReported(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "ok"})
+	errs := r.ErrorLog()
+	msg := edit(t, dir, watchers, map[string]string{"index.html": "{{if}}"})
+	if msg.Type != MessageError {
+		t.Errorf("got %s message, want %s", msg.Type, MessageError)
+	}
+	select {
+	case err := <-errs:
+		var changeErr *ChangeError
+		if !errors.As(err, &changeErr) || changeErr.Path != filepath.Join(dir, "index.html") {
+			t.Errorf("got error %v, want a ChangeError for index.html", err)
+		}
+	default:
+		t.Error("the parse failure wasn't reported")
+	}
+}
+
+func TestWatcherErrorIsReported(t *testing.T) {
+	r, _, watchers := newTestReloader(t, nil)
+	errs := r.ErrorLog()
+	failure := errors.New("inotify queue broken")
+	watchers.last().SendError(failure)
+	select {
+	case err := <-errs:
+		var changeErr *ChangeError
+		if !errors.As(err, &changeErr) || changeErr.Path != "" || !errors.Is(err, failure) {
+			t.Errorf("got error %v, want a ChangeError of the watcher wrapping %v", err, failure)
+		}
+	case <-time.After(testTimeout):
+		t.Error("the watcher error wasn't reported")
+	}
+}
+
+func TestErrorLogDropsWhenFull(t *testing.T) {
+	r, _, _ := newTestReloader(t, nil)
+	r.ErrorLog()
+	for i := 0; i < errorBuffer+3; i++ {
+		r.errors.print(fmt.Errorf("failure %d", i))
+	}
+	if n := r.Stats().ErrorsDropped; n != 3 {
+		t.Errorf("ErrorsDropped = %d, want 3", n)
+	}
+}
+
+func TestErrorLogCollapsesRepeats(t *testing.T) {
+	var l errorLog
+	err := errors.New("same failure")
+	for i := 0; i < 3; i++ {
+		l.print(err)
+	}
+	l.print(errors.New("other failure"))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n := l.repeats["same failure"]; n != 2 {
+		t.Errorf("same failure repeated %d more times, want 2", n)
+	}
+	if n := l.repeats["other failure"]; n != 0 {
+		t.Errorf("other failure repeated %d more times, want 0", n)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+
+	"github.com/yuin/goldmark"
+)
+
+// MarkdownExt is the extension of Markdown pages enabled by WithMarkdown.
+const MarkdownExt = ".md"
+
+// WithMarkdown serves Markdown files as pages: they're converted to HTML
+// when loaded and reloaded, and rendered by executing the template layout
+// with MarkdownData, so it can place the page with {{.Content}}. Without a
+// layout the converted HTML is served as is. Front matter isn't supported.
+func WithMarkdown(layout string) Option {
+	return optionFunc(func(r *Reloader) {
+		WithEngine(Markdown, MarkdownExt).apply(r)
+		r.layout = layout
+	})
+}
+
+// MarkdownData is what the layout of Markdown pages is executed with.
+type MarkdownData struct {
+	// Content is the page converted to HTML.
+	Content htmltemplate.HTML
+	// Data is the data the page was rendered with.
+	Data interface{}
+}
+
+// markdownPage is a Markdown file converted to HTML, which is a Template
+// rendering through the layout.
+type markdownPage struct {
+	name    string
+	content htmltemplate.HTML
+	// layout returns the current layout, nil if there is none.
+	layout func() Template
+}
+
+func (p *markdownPage) Name() string { return p.name }
+
+func (p *markdownPage) Execute(w io.Writer, data interface{}) error {
+	layout := p.layout()
+	if layout == nil {
+		_, err := io.WriteString(w, string(p.content))
+		return err
+	}
+	return layout.Execute(w, MarkdownData{p.content, data})
+}
+
+func (p *markdownPage) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	if name == p.name {
+		return p.Execute(w, data)
+	}
+	layout := p.layout()
+	if layout == nil {
+		return fmt.Errorf("markdown: no template %q in %s", name, p.name)
+	}
+	return layout.ExecuteTemplate(w, name, MarkdownData{p.content, data})
+}
+
+// parseMarkdown converts the Markdown files into a page called name.
+func parseMarkdown(s parseSettings, name string, files ...string) (Template, error) {
+	var buf bytes.Buffer
+	for _, file := range files {
+		src, err := s.readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := goldmark.Convert(src, &buf); err != nil {
+			return nil, fmt.Errorf("markdown: %s: %w", file, err)
+		}
+	}
+	layout := s.layout
+	if layout == nil {
+		layout = func() Template { return nil }
+	}
+	return &markdownPage{name, htmltemplate.HTML(buf.String()), layout}, nil
+}
+
+// markdownLayout returns the layout of Markdown pages, nil if there is
+// none or it's missing.
+func (r *Reloader) markdownLayout() Template {
+	if r.layout == "" {
+		return nil
+	}
+	return r.lookup(r.layout)
+}
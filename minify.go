@@ -0,0 +1,142 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// WithMinify minifies HTML templates before parsing them, collapsing runs
+// of whitespace and stripping comments, to preview what a minified
+// production build serves. Actions are left intact, and so are quoted
+// attribute values and the contents of pre, textarea, script and style
+// elements. Templates are
+// minified the same way whenever they're reparsed.
+func WithMinify() Option {
+	return optionFunc(func(r *Reloader) { r.minify = true })
+}
+
+// verbatimElements are the elements whose contents are never minified.
+var verbatimElements = []string{"pre", "textarea", "script", "style"}
+
+// minifyHTML collapses whitespace and strips comments in the HTML template
+// text, leaving actions delimited by left and right, "{{" and "}}" if
+// empty, and quoted attribute values untouched. Comments containing
+// actions are kept, as dropping them could unbalance {{define}} and
+// {{end}}.
+func minifyHTML(text, left, right string) string {
+	if left == "" {
+		left = "{{"
+	}
+	if right == "" {
+		right = "}}"
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	// space is whether the last byte written is a collapsed space, so
+	// whitespace around a stripped comment collapses too.
+	space := false
+	// inTag is whether text[i] is inside a tag, where quotes delimit
+	// attribute values rather than being text.
+	inTag := false
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch {
+		case strings.HasPrefix(rest, left):
+			n := actionLen(rest, left, right)
+			b.WriteString(rest[:n])
+			space = false
+			i += n
+		case strings.HasPrefix(rest, "<!--") && !strings.HasPrefix(rest, "<!--["):
+			n := len(rest)
+			if end := strings.Index(rest, "-->"); end >= 0 {
+				n = end + len("-->")
+			}
+			if strings.Contains(rest[:n], left) {
+				b.WriteString(rest[:n])
+				space = false
+			}
+			i += n
+		case rest[0] == '<' && verbatimElement(rest) != "":
+			n := len(rest)
+			closing := "</" + verbatimElement(rest)
+			if end := strings.Index(strings.ToLower(rest), closing); end >= 0 {
+				n = end
+			}
+			b.WriteString(rest[:n])
+			space = false
+			i += n
+		case inTag && (rest[0] == '"' || rest[0] == '\''):
+			n := quotedLen(rest, left, right)
+			b.WriteString(rest[:n])
+			space = false
+			i += n
+		case isSpace(rest[0]):
+			n := 1
+			for n < len(rest) && isSpace(rest[n]) {
+				n++
+			}
+			if !space {
+				b.WriteByte(' ')
+				space = true
+			}
+			i += n
+		default:
+			if rest[0] == '<' && len(rest) > 1 && (isLetter(rest[1]) || rest[1] == '/') {
+				inTag = true
+			} else if rest[0] == '>' {
+				inTag = false
+			}
+			b.WriteByte(rest[0])
+			space = false
+			i++
+		}
+	}
+	return b.String()
+}
+
+// actionLen returns the length of the action text begins with, up to the
+// end of text if it's unterminated.
+func actionLen(text, left, right string) int {
+	if end := strings.Index(text[len(left):], right); end >= 0 {
+		return len(left) + end + len(right)
+	}
+	return len(text)
+}
+
+// quotedLen returns the length of the attribute value text begins with,
+// quotes included, skipping over the quotes of the actions inside it.
+func quotedLen(text, left, right string) int {
+	for i := 1; i < len(text); {
+		switch {
+		case strings.HasPrefix(text[i:], left):
+			i += actionLen(text[i:], left, right)
+		case text[i] == text[0]:
+			return i + 1
+		default:
+			i++
+		}
+	}
+	return len(text)
+}
+
+// verbatimElement returns the name of the verbatim element whose start tag
+// text begins with, or "".
+func verbatimElement(text string) string {
+	for _, name := range verbatimElements {
+		tag := "<" + name
+		if len(text) > len(tag) && strings.EqualFold(text[:len(tag)], tag) {
+			if c := text[len(tag)]; c == '>' || c == '/' || isSpace(c) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isSpace(c byte) bool {
+	return c < 0x80 && unicode.IsSpace(rune(c))
+}
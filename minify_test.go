@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestMinifyHTML(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"<p>\n\t  one   two\n</p>", "<p> one two </p>"},
+		{"<p>one</p> <!-- note --> <p>two</p>", "<p>one</p> <p>two</p>"},
+		{"<!-- {{define \"x\"}} -->", "<!-- {{define \"x\"}} -->"},
+		{"{{if  .A}}   {{printf \"%s   %s\" .B .C}}{{end}}", "{{if  .A}} {{printf \"%s   %s\" .B .C}}{{end}}"},
+		{"<pre>  keep\n  this</pre>", "<pre>  keep\n  this</pre>"},
+		{`<p title="a   b"   data-x='  c  '>it's   ok</p>`, `<p title="a   b" data-x='  c  '>it's ok</p>`},
+		{`<a title="{{printf "%s" "  x"}}  y">  z</a>`, `<a title="{{printf "%s" "  x"}}  y"> z</a>`},
+		{`<p>"  quoted  text  "</p>`, `<p>" quoted text "</p>`},
+	}
+	for _, tt := range tests {
+		if got := minifyHTML(tt.text, "", ""); got != tt.want {
+			t.Errorf("minifyHTML(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMinifyHTMLDelims(t *testing.T) {
+	text := "[[  .A  ]]   <p>{{  x  }}</p>"
+	if got, want := minifyHTML(text, "[[", "]]"), "[[  .A  ]] <p>{{ x }}</p>"; got != want {
+		t.Errorf("minifyHTML(%q) = %q, want %q", text, got, want)
+	}
+}
+
+func TestMinifySurvivesReloads(t *testing.T) {
+	const page = `<!-- the home page -->
+<html>
+  <body   class="home">
+    <h1 title="  {{.Title}}  ">  {{.Title}}  </h1>
+    {{range .Items}}
+      <li>  {{.}}  </li>
+    {{end}}
+  </body>
+</html>
+`
+	data := map[string]interface{}{"Title": "Home", "Items": []string{"a", "b"}}
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": page}, WithMinify())
+	preloaded := execute(t, r, "index", data)
+	if want := ` <html> <body class="home"> <h1 title="  Home  "> Home </h1>  <li> a </li>  <li> b </li>  </body> </html> `; preloaded != want {
+		t.Errorf("preloaded index = %q, want %q", preloaded, want)
+	}
+
+	edit(t, dir, watchers, map[string]string{"index.html": "<p>changed</p>"})
+	edit(t, dir, watchers, map[string]string{"index.html": page})
+	if reloaded := execute(t, r, "index", data); reloaded != preloaded {
+		t.Errorf("reloaded index = %q, want it as preloaded: %q", reloaded, preloaded)
+	}
+}
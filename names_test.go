@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestNamesAndDescribe(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"index.html":       "index",
+		"about.html":       "about",
+		"admin/users.html": "users",
+	})
+	if names, want := r.Names(), []string{"about", "admin/users", "index"}; !slices.Equal(names, want) {
+		t.Fatalf("Names() = %v, want %v", names, want)
+	}
+	edit(t, dir, watchers, map[string]string{"about.html": "{{if}}"})
+	infos := r.Describe()
+	if len(infos) != 3 {
+		t.Fatalf("Describe() = %v, want 3 templates", infos)
+	}
+	for _, info := range infos {
+		if want := filepath.Join(dir, filepath.FromSlash(info.Key)+".html"); info.Path != want {
+			t.Errorf("%s: Path = %q, want %q", info.Key, info.Path, want)
+		}
+		if info.Loaded.IsZero() {
+			t.Errorf("%s: no load time", info.Key)
+		}
+		if broken := info.Key == "about"; broken != (info.Err != "") {
+			t.Errorf("%s: Err = %q", info.Key, info.Err)
+		}
+	}
+
+	// What's returned is the caller's.
+	r.Names()[0] = "changed"
+	infos[0].Key = "changed"
+
+	path := filepath.Join(dir, "about.html")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	since := currentVersion()
+	watchers.last().Send(path, fsnotify.Remove)
+	published(t, since)
+	if names, want := r.Names(), []string{"admin/users", "index"}; !slices.Equal(names, want) {
+		t.Errorf("Names() = %v once about was removed, want %v", names, want)
+	}
+	if infos := r.Describe(); len(infos) != 2 || infos[0].Key != "admin/users" || infos[1].Key != "index" {
+		t.Errorf("Describe() = %v once about was removed", infos)
+	}
+}
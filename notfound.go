@@ -0,0 +1,71 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// maxListedNames caps how many template names the not found page lists.
+const maxListedNames = 50
+
+// notFound is served in development for a template name that doesn't
+// exist, listing those that do so a mismatch like "./index" for "index"
+// stands out. It reloads like any other page, so creating the template
+// brings it up.
+var notFound = template.Must(template.Must(clientScript.Clone()).New("notfound").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Not found: {{.Key}}</title>
+</head>
+<body>
+<h1>template {{printf "%q" .Key}} not found{{with .In}} in {{printf "%q" .}}{{end}}</h1>
+{{if .Names}}
+<p>Templates {{if .In}}defined{{else}}registered{{end}}:</p>
+<ul>
+    {{range .Names}}<li>{{if $.In}}{{.}}{{else}}<a href="/{{.}}">{{.}}</a>{{end}}</li>{{end}}
+</ul>
+{{if .More}}<p>and {{.More}} more.</p>{{end}}
+{{else}}
+<p>No templates are registered.</p>
+{{end}}
+{{template "client" .Client}}
+</body>
+</html>
+`))
+
+type notFoundData struct {
+	Client clientTag
+	Key    string
+	// In is the template Key was looked for in, if it's a template
+	// defined in another.
+	In    string
+	Names []string
+	// More is how many names were left out.
+	More int
+}
+
+// renderNotFound responds that there is no template key, listing the names
+// there are in development. With -prod it's a plain 404.
+func renderNotFound(reloader *Reloader, w http.ResponseWriter, host, key string) {
+	renderMissing(reloader, w, host, notFoundData{Key: key, Names: reloader.Names()})
+}
+
+// renderMissing responds with the not found page for data, or the error
+// template for 404 if there is one. With -prod it's a plain 404.
+func renderMissing(reloader *Reloader, w http.ResponseWriter, host string, data notFoundData) {
+	if reloader.renderError(w, host, http.StatusNotFound, data.Key, nil) {
+		return
+	}
+	if *production {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	data.Client = reloader.clientTag(w, host, *clientMode)
+	if len(data.Names) > maxListedNames {
+		data.More = len(data.Names) - maxListedNames
+		data.Names = data.Names[:maxListedNames]
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	notFound.Execute(w, data)
+}
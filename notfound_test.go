@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNotFoundListsNames(t *testing.T) {
+	s := NewTestServer(t)
+	files := map[string]string{}
+	for i := 0; i < maxListedNames+10; i++ {
+		files[fmt.Sprintf("page%02d.html", i)] = "page"
+	}
+	writeFiles(t, s.Dir, files)
+	s.Scan()
+
+	status, body := s.fetch("/indx")
+	if status != http.StatusNotFound {
+		t.Errorf("status %d, want %d", status, http.StatusNotFound)
+	}
+	if !strings.Contains(body, "template &#34;indx&#34; not found") {
+		t.Errorf("page doesn't say indx isn't found:\n%s", body)
+	}
+	first := strings.Index(body, `href="/page00"`)
+	if first < 0 || strings.Index(body, `href="/page49"`) < first {
+		t.Errorf("page doesn't list the first %d names in order:\n%s", maxListedNames, body)
+	}
+	if strings.Contains(body, "page50") || !strings.Contains(body, "and 10 more.") {
+		t.Errorf("page doesn't leave out the names beyond %d:\n%s", maxListedNames, body)
+	}
+
+	setFlag(t, production, true)
+	if _, body := s.fetch("/indx"); strings.Contains(body, "page00") {
+		t.Errorf("page with -prod lists names:\n%s", body)
+	}
+}
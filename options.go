@@ -0,0 +1,151 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Option configures a Reloader in New. A Root is an Option adding the
+// root.
+type Option interface {
+	apply(r *Reloader)
+}
+
+type optionFunc func(r *Reloader)
+
+func (f optionFunc) apply(r *Reloader) { f(r) }
+
+func (root Root) apply(r *Reloader) {
+	if root.Loader != nil {
+		r.loaders = append(r.loaders, &loaderState{Loader: root.Loader, root: root})
+		return
+	}
+	r.roots = append(r.roots, root)
+}
+
+// WithExclude keeps paths matching any of patterns from being watched,
+// parsed or reloaded, like
+//
+//	New(Root{Path: "./"}, WithExclude("node_modules/**", "dist/**", "*.min.html"))
+//
+// Patterns are matched against the path relative to its root, with "/" or
+// "\" separators, where "**" matches any number of directories and a
+// pattern without a separator matches the file name at any depth.
+func WithExclude(patterns ...string) Option {
+	return optionFunc(func(r *Reloader) {
+		for _, pattern := range patterns {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				r.Exclude = append(r.Exclude, pattern)
+			}
+		}
+	})
+}
+
+// WithEventFilter replaces DefaultEventFilter in deciding which changes
+// reload anything. Events filter returns false for are dropped quietly, so
+// this reloads only for changes below pages/:
+//
+//	WithEventFilter(func(evt fsnotify.Event) bool {
+//		return strings.HasPrefix(evt.Name, "pages/") && DefaultEventFilter(evt)
+//	})
+//
+// Since changes to a file are collected until it settles, evt.Op may
+// combine several operations.
+func WithEventFilter(filter func(fsnotify.Event) bool) Option {
+	return optionFunc(func(r *Reloader) { r.eventFilter = filter })
+}
+
+// isExcluded reports whether name matches one of the Exclude patterns or
+// those of its root, or is left out by the .liveignore file of its root.
+func (r *Reloader) isExcluded(name string) bool {
+	root, _, _ := r.fileRoot(name)
+	if len(r.Exclude) == 0 && len(root.Exclude) == 0 {
+		return r.isLiveignored(name)
+	}
+	rel := slashed(r.relative(name))
+	for _, patterns := range [][]string{root.Exclude, r.Exclude} {
+		for _, pattern := range patterns {
+			if matchGlob(slashedPattern(pattern), rel) {
+				return true
+			}
+		}
+	}
+	return r.isLiveignored(name)
+}
+
+// relative returns name relative to the root or static directory holding
+// it, or name itself outside of them.
+func (r *Reloader) relative(name string) string {
+	dir := ""
+	if root, _, ok := r.fileRoot(name); ok {
+		dir = root.Path
+	} else {
+		for _, static := range r.static {
+			if isWithin(name, static) {
+				dir = static
+			}
+		}
+	}
+	if dir == "" {
+		return name
+	}
+	if rel, err := filepath.Rel(dir, name); err == nil {
+		return rel
+	}
+	return name
+}
+
+// slashed returns the path name with the separators of this OS as
+// slashes. Backslashes in file names on Unix are left alone.
+func slashed(name string) string {
+	return toSlash(name, filepath.Separator)
+}
+
+// toSlash returns name with the separator sep as slashes.
+func toSlash(name string, sep byte) string {
+	if sep == '/' {
+		return name
+	}
+	return strings.ReplaceAll(name, string(sep), "/")
+}
+
+// slashedPattern returns the user supplied pattern or directory with
+// either separator as slashes, so one written for Windows works anywhere.
+func slashedPattern(pattern string) string {
+	return strings.ReplaceAll(filepath.ToSlash(pattern), `\`, "/")
+}
+
+// matchGlob reports whether the slash separated name matches pattern,
+// where "**" matches any number of path elements, including none.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchElems(strings.Split(strings.Trim(pattern, "/"), "/"),
+		strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"node_modules/**", "node_modules/pkg/index.html", true},
+		{"node_modules/**", "node_modules", true},
+		{"node_modules/**", "src/node_modules/index.html", false},
+		{"**/node_modules/**", "src/node_modules/index.html", true},
+		{"dist/**", "distant/index.html", false},
+		{"*.min.html", "index.min.html", true},
+		{"*.min.html", "assets/app.min.html", true},
+		{"*.min.html", "index.html", false},
+		{"admin/*.html", "admin/users.html", true},
+		{"admin/*.html", "admin/users/list.html", false},
+		{slashedPattern(`dist\**`), "dist/index.html", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExclude(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"index.html":                "index",
+		"node_modules/pkg/doc.html": "doc",
+		"dist/index.html":           "dist",
+		"app.min.html":              "min",
+	}, WithExclude("node_modules/**", `dist\**`, "*.min.html"))
+
+	for _, sub := range []string{"node_modules", "node_modules/pkg", "dist"} {
+		if watchers.last().Watched(filepath.Join(dir, sub)) {
+			t.Errorf("excluded directory %s is watched", sub)
+		}
+	}
+	if want := []string{"index"}; !slices.Equal(r.Names(), want) {
+		t.Errorf("Names() = %v, want %v", r.Names(), want)
+	}
+	for _, key := range []string{"node_modules/pkg/doc", "dist/index", "app.min"} {
+		if _, err := r.Get(key); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("Get(%q) = %v, want ErrTemplateNotFound", key, err)
+		}
+	}
+	if !r.isIgnored(filepath.Join(dir, "dist", "index.html")) {
+		t.Error("changes to dist/index.html aren't ignored")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	htmltemplate "html/template"
+	"sort"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// templateOutline is what the parse tree of one template in a set tells:
+// the file it was parsed from and the templates it invokes.
+type templateOutline struct {
+	parseName string
+	refs      []string
+}
+
+// setOutline maps the names of the templates a set defines to their
+// outlines.
+type setOutline map[string]templateOutline
+
+// outline reads the outline of tmpl's set from its parse trees. It has to
+// be read before the set is first executed, as html/template rewrites the
+// trees then, renaming the templates invoked, and would race with reading
+// them.
+func outline(tmpl Template) setOutline {
+	if b, ok := tmpl.(blockTemplate); ok {
+		tmpl = b.Template
+	}
+	o := setOutline{}
+	for _, name := range defined(tmpl) {
+		if tree := lookupTree(tmpl, name); tree != nil && tree.Root != nil {
+			o[name] = templateOutline{tree.ParseName, templateRefs(tree.Root)}
+		}
+	}
+	return o
+}
+
+// names returns the sorted names of the templates in o.
+func (o setOutline) names() []string {
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// outline returns the template key and the outline of its set, taken when
+// it was stored. Templates added at runtime and sets are outlined now, best
+// effort.
+func (r *Reloader) outline(key string) (Template, setOutline) {
+	tmpl := r.lookup(key)
+	if tmpl == nil {
+		return nil, nil
+	}
+	r.RLock()
+	o, ok := r.outlines[key]
+	r.RUnlock()
+	if !ok {
+		o = outline(tmpl)
+	}
+	return tmpl, o
+}
+
+// lookupTree returns the parse tree of the template called name in the
+// set tmpl belongs to.
+func lookupTree(tmpl Template, name string) *parse.Tree {
+	switch t := tmpl.(type) {
+	case *htmltemplate.Template:
+		if t = t.Lookup(name); t != nil {
+			return t.Tree
+		}
+	case *texttemplate.Template:
+		if t = t.Lookup(name); t != nil {
+			return t.Tree
+		}
+	case blockTemplate:
+		return lookupTree(t.Template, name)
+	}
+	return nil
+}
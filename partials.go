@@ -0,0 +1,100 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultPartialPrefix marks template files that are partials, such as
+// "_nav.html". Partials are parsed into every page, so pages can include
+// them with {{template "_nav.html" .}}, but are never rendered on their own.
+const DefaultPartialPrefix = "_"
+
+// isPartial reports whether the file or key name is a partial.
+func (r *Reloader) isPartial(name string) bool {
+	for _, dir := range r.PartialDirs {
+		if strings.HasPrefix(slashed(name), strings.Trim(slashedPattern(dir), "/")+"/") {
+			return true
+		}
+	}
+	if r.isLayout(name) {
+		return true
+	}
+	return r.PartialPrefix != "" &&
+		strings.HasPrefix(filepath.Base(name), r.PartialPrefix)
+}
+
+// partialFiles returns the paths of all known partials parsed with engine,
+// sorted by key so pages always parse them in the same order.
+func (r *Reloader) partialFiles(engine Engine) []string {
+	r.RLock()
+	defer r.RUnlock()
+	keys := make([]string, 0, len(r.partials))
+	for key, path := range r.partials {
+		if r.engine(path) == engine {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	files := make([]string, len(keys))
+	for i, key := range keys {
+		files[i] = r.partials[key]
+	}
+	return files
+}
+
+// parsePage parses the page at path together with all partials of the
+// same engine, timing it, and wraps it in the layout.
+func (r *Reloader) parsePage(path string) (Template, error) {
+	defer r.timeParse(path, time.Now())
+	engine := r.engine(path)
+	tmpl, err := engine.parseFiles(r.parseSettings(), path, r.partialFiles(engine)...)
+	if err != nil {
+		return nil, err
+	}
+	return r.withLayout(path, tmpl), nil
+}
+
+// parseSettings returns the settings templates are parsed with.
+func (r *Reloader) parseSettings() parseSettings {
+	return parseSettings{
+		funcs:         r.funcs(),
+		options:       r.options(),
+		left:          r.left,
+		right:         r.right,
+		minify:        r.minify,
+		layout:        r.markdownLayout,
+		preprocessors: r.preprocessors,
+		loader:        r.fileLoader,
+	}
+}
+
+// reloadPages reparses every page, so they pick up a changed partial.
+func (r *Reloader) reloadPages() error {
+	r.RLock()
+	sources := make(map[string]string, len(r.sources))
+	for key, path := range r.sources {
+		sources[key] = path
+	}
+	r.RUnlock()
+	return r.reparse(sources)
+}
+
+// reparse parses the pages in sources, which maps their keys to their
+// files, returning the first error.
+func (r *Reloader) reparse(sources map[string]string) error {
+	var firstErr error
+	r.parseEach(sources, func(key, path string, tmpl Template, err error) {
+		if err != nil {
+			err = r.broken(key, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		r.store(key, path, tmpl)
+	})
+	return firstErr
+}
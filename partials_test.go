@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var navFiles = map[string]string{
+	"index.html": `{{template "_nav.html" .}}<p>index</p>`,
+	"_nav.html":  `<nav>one</nav>`,
+}
+
+func TestPartialChangeReloadsPages(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, navFiles)
+	if out := execute(t, r, "index", nil); out != "<nav>one</nav><p>index</p>" {
+		t.Fatalf("index = %q", out)
+	}
+
+	msg := edit(t, dir, watchers, map[string]string{"_nav.html": "<nav>two</nav>"})
+	if msg.Type != MessageReload {
+		t.Fatalf("got %s message, want %s", msg.Type, MessageReload)
+	}
+	if out := execute(t, r, "index", nil); out != "<nav>two</nav><p>index</p>" {
+		t.Errorf("index = %q after the partial changed", out)
+	}
+}
+
+func TestPartialIsNotAPage(t *testing.T) {
+	r, _, _ := newTestReloader(t, navFiles)
+	if slices.Contains(r.pages(), "_nav") {
+		t.Errorf("pages() = %v, want no _nav", r.pages())
+	}
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_nav", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /_nav = %d, want 404", w.Code)
+	}
+
+	out := t.TempDir()
+	if err := r.Export(out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "_nav.html")); err == nil {
+		t.Error("the partial _nav was exported")
+	}
+}
+
+func TestPartialPrefixConfigurable(t *testing.T) {
+	r, _, _ := newTestReloader(t, nil)
+	r.PartialPrefix = "partial-"
+	if !r.isPartial("partial-nav.html") || r.isPartial("_nav.html") {
+		t.Errorf("with PartialPrefix %q, partial-nav is partial: %v, _nav: %v",
+			r.PartialPrefix, r.isPartial("partial-nav.html"), r.isPartial("_nav.html"))
+	}
+	r.PartialPrefix = ""
+	if r.isPartial("_nav.html") {
+		t.Error("_nav is a partial with the convention off")
+	}
+}
+
+func TestPartialDirs(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"layouts/base.html": `{{define "base"}}{{template "nav"}}<main>{{block "content" .}}{{end}}</main>{{end}}`,
+		"partials/nav.html": `{{define "nav"}}<nav>one</nav>{{end}}`,
+		"index.html":        `{{template "base" .}}{{define "content"}}index{{end}}`,
+		"about.html":        `{{template "base" .}}{{define "content"}}about{{end}}`,
+	})
+	r.PartialDirs = []string{"layouts", "partials"}
+	r.Scan()
+	if want := []string{"about", "index"}; !slices.Equal(r.pages(), want) {
+		t.Errorf("pages() = %v, want %v", r.pages(), want)
+	}
+	for _, key := range []string{"index", "about"} {
+		if out, want := execute(t, r, key, nil), "<nav>one</nav><main>"+key+"</main>"; out != want {
+			t.Errorf("%s = %q, want %q", key, out, want)
+		}
+	}
+
+	edit(t, dir, watchers, map[string]string{"partials/nav.html": `{{define "nav"}}<nav>two</nav>{{end}}`})
+	for _, key := range []string{"index", "about"} {
+		if out, want := execute(t, r, key, nil), "<nav>two</nav><main>"+key+"</main>"; out != want {
+			t.Errorf("%s = %q once the nav changed, want %q", key, out, want)
+		}
+	}
+
+	// A new partial joins the pages using it.
+	edit(t, dir, watchers, map[string]string{
+		"partials/footer.html": `{{define "footer"}}<footer>new</footer>{{end}}`,
+		"about.html":           `{{template "base" .}}{{define "content"}}about{{template "footer"}}{{end}}`,
+	})
+	if out, want := execute(t, r, "about", nil), "<nav>two</nav><main>about<footer>new</footer></main>"; out != want {
+		t.Errorf("about = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+)
+
+// placeholder is served at "/" while there is no index template, so a fresh
+// checkout shows what is being watched instead of an error. It reloads like
+// any other page once templates show up.
+var placeholder = template.Must(template.Must(clientScript.Clone()).New("placeholder").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Live reload</title>
+</head>
+<body>
+<h1>Waiting for templates</h1>
+<p>Watching {{range $i, $root := .Roots}}{{if $i}}, {{end}}<code>{{$root}}</code>{{end}}
+for files ending in {{range $i, $ext := .Exts}}{{if $i}}, {{end}}<code>{{$ext}}</code>{{end}}.
+Create <code>index{{index .Exts 0}}</code>
+and this page will reload into it.</p>
+{{if .Pages}}
+<p>Templates found so far:</p>
+<ul>
+    {{range .Pages}}<li><a href="/{{.}}">{{.}}</a></li>{{end}}
+</ul>
+{{end}}
+{{template "client" .Client}}
+</body>
+</html>
+`))
+
+type placeholderData struct {
+	Client clientTag
+	Roots  []string
+	Exts   []string
+	Pages  []string
+}
+
+// pages returns the sorted keys of all templates that can be served.
+func (r *Reloader) pages() []string {
+	r.RLock()
+	defer r.RUnlock()
+	templates := r.templates.load()
+	keys := make([]string, 0, len(templates))
+	for key := range templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// exts returns the template extensions of all roots.
+func (r *Reloader) exts() []string {
+	var exts []string
+	seen := map[string]bool{}
+	for _, root := range r.rootList() {
+		for _, ext := range root.exts() {
+			if !seen[ext] {
+				seen[ext] = true
+				exts = append(exts, ext)
+			}
+		}
+	}
+	if len(exts) == 0 {
+		exts = []string{TemplateExt}
+	}
+	return exts
+}
+
+func renderPlaceholder(reloader *Reloader, w http.ResponseWriter, host string) error {
+	return placeholder.Execute(w, placeholderData{
+		Client: reloader.clientTag(w, host, *clientMode),
+		Roots:  reloader.rootPaths(),
+		Exts:   reloader.exts(),
+		Pages:  reloader.pages(),
+	})
+}
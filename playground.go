@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// playgroundPath prefixes the playground routes: /__play/{key} renders
+	// the template stored under key.
+	playgroundPath = "/__play/"
+
+	// maxPlaygroundData caps the size of the JSON payload, whether it comes
+	// from the query string or the request body.
+	maxPlaygroundData = 1 << 20
+)
+
+// getServePlayground renders a template with JSON data taken from the "data"
+// query parameter on GET, or from the request body on POST. Execution
+// errors are returned as plain text so they can be read in the browser.
+// The rendered page is followed by a reload client, so it reloads like any
+// other page.
+func getServePlayground(reloader *Reloader) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, playgroundPath)
+		tmpl, err := reloader.Get(name)
+		if errors.Is(err, ErrTemplateNotFound) {
+			http.Error(w, fmt.Sprintf("Template %q not found", name),
+				http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var raw []byte
+		if r.Method == http.MethodPost {
+			var err error
+			raw, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxPlaygroundData))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+		} else {
+			raw = []byte(r.URL.Query().Get("data"))
+		}
+		if len(raw) > maxPlaygroundData {
+			http.Error(w, "Data too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		var data interface{}
+		if len(bytes.TrimSpace(raw)) > 0 {
+			if err := json.Unmarshal(raw, &data); err != nil {
+				http.Error(w, "Invalid data: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		buf := reloader.buffers.get()
+		defer reloader.buffers.put(buf)
+		if err := tmpl.Execute(buf, data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		buf.WriteTo(w)
+		clientScript.Execute(w, reloader.clientTag(w, r.Host, *clientMode))
+	})
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPlayground(t *testing.T) {
+	setFlag(t, play, true)
+	s := NewTestServer(t)
+	s.WriteTemplate("card.html", "<p>{{.name}}</p>")
+	path := playgroundPath + "card?data=" + url.QueryEscape(`{"name": "Ada"}`)
+	status, body := s.fetch(path)
+	if status != http.StatusOK || !strings.HasPrefix(body, "<p>Ada</p>") {
+		t.Errorf("got %d %q, want card rendered with the data", status, body)
+	}
+}
+
+func TestPlaygroundIsDevOnly(t *testing.T) {
+	setFlag(t, play, true)
+	setFlag(t, production, true)
+	s := NewTestServer(t)
+	s.WriteTemplate("card.html", "<p>{{.name}}</p>")
+	path := playgroundPath + "card?data=" + url.QueryEscape(`{"name": "Ada"}`)
+	if status, body := s.fetch(path); status != http.StatusNotFound {
+		t.Errorf("got %d %q with -prod, want the playground not served", status, body)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultPollInterval is how often WithPolling walks the directories unless
+// told otherwise.
+const DefaultPollInterval = time.Second
+
+// WithPolling makes the Reloader find changes by walking its directories
+// every interval, DefaultPollInterval if zero, instead of relying on
+// filesystem notifications.
+func WithPolling(interval time.Duration) Option {
+	return optionFunc(func(r *Reloader) {
+		if interval <= 0 {
+			interval = DefaultPollInterval
+		}
+		r.pollInterval = interval
+	})
+}
+
+// WithHybrid watches with filesystem notifications where they work, and
+// polls every interval, DefaultPollInterval if zero, the directories where
+// a probe file written at startup wasn't reported.
+func WithHybrid(interval time.Duration) Option {
+	return optionFunc(func(r *Reloader) {
+		if interval <= 0 {
+			interval = DefaultPollInterval
+		}
+		r.hybridInterval = interval
+	})
+}
+
+const (
+	// probePrefix starts the names of the files written to probe whether
+	// notifications work.
+	probePrefix = ".livereload-probe-"
+	// probeTimeout is how long to wait for the probe file to be reported.
+	probeTimeout = time.Second
+)
+
+// probe reports whether writing a file in dir produces a notification.
+func (r *Reloader) probe(dir string) bool {
+	f, err := os.CreateTemp(dir, probePrefix+"*")
+	if err != nil {
+		debugf("Unable to probe %s: %v\n", dir, err)
+		return false
+	}
+	name := filepath.Clean(f.Name())
+	seen := make(chan struct{}, 1)
+	r.probes.Store(name, seen)
+	defer r.probes.Delete(name)
+	defer os.Remove(name)
+
+	f.WriteString("probe")
+	f.Close()
+	select {
+	case <-seen:
+		return true
+	case <-time.After(probeTimeout):
+		return false
+	}
+}
+
+// probed reports whether name is a probe file, telling the probe waiting
+// for it.
+func (r *Reloader) probed(name string) bool {
+	if !strings.HasPrefix(filepath.Base(name), probePrefix) {
+		return false
+	}
+	if seen, ok := r.probes.Load(filepath.Clean(name)); ok {
+		select {
+		case seen.(chan struct{}) <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// pollUnnotified probes every directory and polls those where
+// notifications don't work.
+func (r *Reloader) pollUnnotified() {
+	var dirs []string
+	for _, dir := range append(r.rootDirs(), r.static...) {
+		if !r.probe(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return
+	}
+	fmt.Printf("No change notifications from %v; polling them every %v.\n",
+		dirs, r.hybridInterval)
+	r.AddSource(newPoller(r.hybridInterval, dirs, r.walkOptions()))
+}
+
+// Poller is a ChangeSource that walks directories at an interval and
+// reports files whose modification time or size changed. It works where
+// filesystem notifications don't, like Docker bind mounts on macOS and
+// Windows, network filesystems or directories synced from elsewhere.
+type Poller struct {
+	events chan ChangeEvent
+	done   chan struct{}
+	once   sync.Once
+	opts   walkOptions
+}
+
+// NewPoller starts polling dirs every interval.
+func NewPoller(interval time.Duration, dirs ...string) *Poller {
+	return newPoller(interval, dirs, walkOptions{})
+}
+
+func newPoller(interval time.Duration, dirs []string, opts walkOptions) *Poller {
+	p := &Poller{
+		events: make(chan ChangeEvent),
+		done:   make(chan struct{}),
+		opts:   opts,
+	}
+	// Files are compared to their state now, so changes made once the
+	// Poller is returned are reported.
+	go p.poll(interval, dirs, p.states(dirs))
+	return p
+}
+
+// fileState is what the Poller compares to notice a change.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func (p *Poller) poll(interval time.Duration, dirs []string, before map[string]fileState) {
+	defer close(p.events)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		now := p.states(dirs)
+		var changes []ChangeEvent
+		for path, state := range now {
+			if old, ok := before[path]; !ok {
+				changes = append(changes, ChangeEvent{path, fsnotify.Create})
+			} else if !state.modTime.Equal(old.modTime) || state.size != old.size {
+				changes = append(changes, ChangeEvent{path, fsnotify.Write})
+			}
+		}
+		for path := range before {
+			if _, ok := now[path]; !ok {
+				changes = append(changes, ChangeEvent{path, fsnotify.Remove})
+			}
+		}
+		before = now
+
+		for _, evt := range changes {
+			select {
+			case p.events <- evt:
+			case <-p.done:
+				return
+			}
+		}
+	}
+}
+
+// states returns the state of every file below dirs.
+func (p *Poller) states(dirs []string) map[string]fileState {
+	_, files := walk(dirs, p.opts)
+	states := map[string]fileState{}
+	for _, list := range files {
+		for _, f := range list {
+			states[f.path] = fileState{f.modTime, f.size}
+		}
+	}
+	return states
+}
+
+func (p *Poller) Events() <-chan ChangeEvent { return p.events }
+
+// Close stops polling.
+func (p *Poller) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// headerMarker is replaced by the test preprocessors with a header.
+const headerMarker = "@header"
+
+func TestPreprocessors(t *testing.T) {
+	var paths []string
+	header := func(path string, src []byte) ([]byte, error) {
+		paths = append(paths, path)
+		return bytes.ReplaceAll(src, []byte(headerMarker), []byte("<h1>{{.}}</h1>")), nil
+	}
+	// Runs second, so it sees the header.
+	upper := func(path string, src []byte) ([]byte, error) {
+		return bytes.ReplaceAll(src, []byte("h1>"), []byte("H1>")), nil
+	}
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": headerMarker + "<p>one</p>"},
+		WithPreprocessor(header), WithPreprocessor(upper))
+	if out := execute(t, r, "index", "Home"); out != "<H1>Home</H1><p>one</p>" {
+		t.Errorf("index = %q once preloaded", out)
+	}
+
+	edit(t, dir, watchers, map[string]string{"index.html": "<p>two</p>" + headerMarker})
+	if out := execute(t, r, "index", "Home"); out != "<p>two</p><H1>Home</H1>" {
+		t.Errorf("index = %q once edited", out)
+	}
+	if len(paths) != 2 || !strings.HasSuffix(paths[1], "index.html") {
+		t.Errorf("preprocessed %v, want index.html twice", paths)
+	}
+}
+
+func TestPreprocessorErrorIsParseError(t *testing.T) {
+	fail := func(path string, src []byte) ([]byte, error) {
+		if bytes.Contains(src, []byte("@fail")) {
+			return nil, errors.New("unknown marker")
+		}
+		return src, nil
+	}
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "one"},
+		WithPreprocessor(fail))
+	msg := edit(t, dir, watchers, map[string]string{"index.html": "@fail"})
+	if msg.Type != MessageError || !strings.Contains(msg.Error, "unknown marker") {
+		t.Errorf("got %s message %q, want the preprocessor's error", msg.Type, msg.Error)
+	}
+	if out := execute(t, r, "index", nil); out != "one" {
+		t.Errorf("index = %q, want the last good one", out)
+	}
+	if err := r.ParseErrors()["index"]; err == nil || !strings.Contains(err.Error(), "unknown marker") {
+		t.Errorf("ParseErrors()[index] = %v, want the preprocessor's error", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+// ProtocolVersion is the version of the websocket message schema. It is sent
+// as the "v" field of every message, and clients ignore messages carrying a
+// version they do not understand.
+const ProtocolVersion = 1
+
+// Message types sent to connected clients.
+const (
+	// MessageReload tells the client that templates changed and the page
+	// should be reloaded.
+	MessageReload = "build_complete"
+
+	// MessageError reports a server-side failure to the client.
+	MessageError = "error"
+
+	// MessageHello is sent by clients when they connect, announcing the
+	// protocol version they speak.
+	MessageHello = "hello"
+
+	// MessageAck is sent by clients to acknowledge a message whose Ack
+	// field was set.
+	MessageAck = "ack"
+)
+
+// messageTypes lists every message type, for the info endpoint. A test
+// checks that it names each of the constants above.
+var messageTypes = []string{MessageReload, MessageError, MessageHello, MessageAck}
+
+// Message is the payload sent to connected clients over the websocket.
+type Message struct {
+	V       int     `json:"v"`
+	Type    string  `json:"type"`
+	Version Version `json:"version"`
+	Error   string  `json:"error,omitempty"`
+	// Files lists the files whose changes caused a reload.
+	Files []string `json:"files,omitempty"`
+	// Templates maps the keys of the templates those files provide to
+	// their new versions, see Reloader.Version.
+	Templates map[string]uint64 `json:"templates,omitempty"`
+	// Warnings are non-fatal problems with the templates, see
+	// Reloader.RefWarnings.
+	Warnings []string `json:"warnings,omitempty"`
+	// Paths limits a reload to pages at these request paths. Every page
+	// reloads when it is empty.
+	Paths []string `json:"paths,omitempty"`
+	// Ack asks the client to reply with a MessageAck of the same Version.
+	Ack bool `json:"ack,omitempty"`
+}
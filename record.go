@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// recordedEvent is a line of a recording, as written by WithRecording and
+// read by NewReplay:
+//
+//	{"time":"2024-05-01T10:00:00.123Z","op":"CREATE|WRITE","path":"templates/index.html"}
+type recordedEvent struct {
+	Time time.Time `json:"time"`
+	Op   string    `json:"op"`
+	Path string    `json:"path"`
+}
+
+// recorder appends events to a recording.
+type recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// WithRecording writes every event the filesystem watcher reports to w as
+// a line of JSON, before anything is filtered, so an editor's exact
+// sequence of events can be replayed with NewReplay.
+func WithRecording(w io.Writer) Option {
+	return optionFunc(func(r *Reloader) {
+		r.recorder = &recorder{enc: json.NewEncoder(w)}
+	})
+}
+
+// record appends evt to the recording, if there is one.
+func (r *Reloader) record(evt fsnotify.Event) {
+	if r.recorder == nil {
+		return
+	}
+	rec := r.recorder
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	err := rec.enc.Encode(recordedEvent{time.Now(), evt.Op.String(), evt.Name})
+	if err != nil {
+		r.errors.print(fmt.Errorf("Unable to record event: %w", err))
+	}
+}
+
+// WithParseHook has parse called with the name of each changed file instead
+// of the file being read and parsed, so a recording can be replayed without
+// the files it was made against. An error parse returns is reported as the
+// template failing to parse. Changes are taken as reported, without
+// checking whether files are still being written or changed at all.
+func WithParseHook(parse func(name string) error) Option {
+	return optionFunc(func(r *Reloader) {
+		r.parseHook = parse
+	})
+}
+
+// stubParse passes the changed file name to the parse hook.
+func (r *Reloader) stubParse(name string) error {
+	err := r.parseHook(name)
+	if key, ok := r.templateKey(name); ok && err != nil {
+		return r.broken(key, err)
+	}
+	return err
+}
+
+// parseOp parses the operations of a recorded event, like "CREATE|WRITE".
+func parseOp(s string) (fsnotify.Op, error) {
+	ops := map[string]fsnotify.Op{
+		"CREATE": fsnotify.Create,
+		"WRITE":  fsnotify.Write,
+		"REMOVE": fsnotify.Remove,
+		"RENAME": fsnotify.Rename,
+		"CHMOD":  fsnotify.Chmod,
+	}
+	var op fsnotify.Op
+	for _, name := range strings.Split(s, "|") {
+		o, ok := ops[strings.TrimSpace(name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown operation %q", name)
+		}
+		op |= o
+	}
+	return op, nil
+}
+
+// Replay is a ChangeSource reporting the events of a recording made with
+// WithRecording, so a reported event sequence can be reproduced against
+// the same files.
+type Replay struct {
+	evts  []recordedEvent
+	ops   []fsnotify.Op
+	speed float64
+
+	events chan ChangeEvent
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewReplay reads the recording from rd and starts replaying it, speed
+// times as fast as it was recorded. Events are replayed without delay if
+// speed is zero.
+func NewReplay(rd io.Reader, speed float64) (*Replay, error) {
+	p := &Replay{
+		speed:  speed,
+		events: make(chan ChangeEvent),
+		done:   make(chan struct{}),
+	}
+	scanner := bufio.NewScanner(rd)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var evt recordedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &evt); err != nil {
+			return nil, fmt.Errorf("recording line %d: %w", line, err)
+		}
+		op, err := parseOp(evt.Op)
+		if err != nil {
+			return nil, fmt.Errorf("recording line %d: %w", line, err)
+		}
+		p.evts = append(p.evts, evt)
+		p.ops = append(p.ops, op)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	go p.replay()
+	return p, nil
+}
+
+func (p *Replay) replay() {
+	defer close(p.events)
+	start := time.Now()
+	for i, evt := range p.evts {
+		if p.speed > 0 {
+			offset := evt.Time.Sub(p.evts[0].Time)
+			wait := time.Until(start.Add(time.Duration(float64(offset) / p.speed)))
+			select {
+			case <-time.After(wait):
+			case <-p.done:
+				return
+			}
+		}
+		select {
+		case p.events <- ChangeEvent{evt.Path, p.ops[i]}:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Events returns the replayed changes. The channel is closed once the
+// recording is done.
+func (p *Replay) Events() <-chan ChangeEvent {
+	return p.events
+}
+
+// Close stops the replay.
+func (p *Replay) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+// replayFile replays the recording in the file name into r's changes.
+func replayFile(r *Reloader, name string, speed float64) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	p, err := NewReplay(f, speed)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Replaying %d events from %s.\n", len(p.evts), name)
+	r.AddSource(p)
+	return nil
+}
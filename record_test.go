@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// lockedBuffer is a bytes.Buffer written to by a recorder while tests read
+// it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// replayEvents has r replay evts as recorded.
+func replayEvents(t *testing.T, r *Reloader, evts ...ChangeEvent) {
+	t.Helper()
+	var recording bytes.Buffer
+	enc := json.NewEncoder(&recording)
+	for _, evt := range evts {
+		enc.Encode(recordedEvent{time.Now(), evt.Op.String(), evt.Path})
+	}
+	p, err := NewReplay(&recording, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddSource(p)
+}
+
+func TestRecordingReplays(t *testing.T) {
+	var recording lockedBuffer
+	_, dir, watchers := newTestReloader(t, map[string]string{"index.html": "one"},
+		WithRecording(&recording))
+	path := filepath.Join(dir, "index.html")
+	watchers.last().Send(path, fsnotify.Write)
+	watchers.last().Send(path, fsnotify.Create|fsnotify.Chmod)
+	waitFor(t, "both events to be recorded", func() bool {
+		return strings.Count(recording.String(), "\n") == 2
+	})
+
+	p, err := NewReplay(strings.NewReader(recording.String()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	var got []ChangeEvent
+	for evt := range p.Events() {
+		got = append(got, evt)
+	}
+	want := []ChangeEvent{{path, fsnotify.Write}, {path, fsnotify.Create | fsnotify.Chmod}}
+	if !slices.Equal(got, want) {
+		t.Errorf("replayed %v, want %v", got, want)
+	}
+}
+
+func TestReplayWithParseHook(t *testing.T) {
+	var mu sync.Mutex
+	var parsed []string
+	r, dir, _ := newTestReloader(t, nil, WithParseHook(func(name string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		parsed = append(parsed, name)
+		if filepath.Base(name) == "broken.html" {
+			return errors.New("unexpected EOF")
+		}
+		return nil
+	}))
+
+	// Neither file exists.
+	index := filepath.Join(dir, "index.html")
+	since := currentVersion()
+	replayEvents(t, r, ChangeEvent{index, fsnotify.Create}, ChangeEvent{index, fsnotify.Write})
+	msg := published(t, since)
+	if msg.Type != MessageReload || !slices.Equal(msg.Files, []string{index}) {
+		t.Errorf("got %s message for %v, want %s for %s", msg.Type, msg.Files, MessageReload, index)
+	}
+	mu.Lock()
+	if !slices.Equal(parsed, []string{index}) {
+		t.Errorf("parsed %v, want just %s", parsed, index)
+	}
+	mu.Unlock()
+
+	since = currentVersion()
+	replayEvents(t, r, ChangeEvent{filepath.Join(dir, "broken.html"), fsnotify.Write})
+	if msg := published(t, since); msg.Type != MessageError {
+		t.Errorf("got %s message, want %s for the hook's error", msg.Type, MessageError)
+	}
+	if _, ok := r.ParseErrors()["broken"]; !ok {
+		t.Error("broken isn't among ParseErrors()")
+	}
+}
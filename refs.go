@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// undefinedRefs returns the names the set outlined by o invokes with
+// {{template}} but doesn't define, mapped to the file invoking them. It's
+// best effort: a template added to the set at runtime, like with
+// AddParseTree, is reported all the same.
+func undefinedRefs(o setOutline) map[string]string {
+	refs := map[string]string{}
+	for _, name := range o.names() {
+		for _, ref := range o[name].refs {
+			if _, seen := refs[ref]; !seen {
+				if _, ok := o[ref]; !ok {
+					refs[ref] = o[name].parseName
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// checkRefs warns about the templates the page key invokes without them
+// being defined, which html/template only notices when executing it, and
+// notes when they're fixed. See RefWarnings.
+func (r *Reloader) checkRefs(key string) {
+	var refs map[string]string
+	if tmpl, o := r.outline(key); tmpl != nil {
+		refs = undefinedRefs(o)
+	}
+	r.Lock()
+	before := r.undefined[key]
+	if len(refs) == 0 {
+		delete(r.undefined, key)
+	} else {
+		if r.undefined == nil {
+			r.undefined = map[string]map[string]string{}
+		}
+		r.undefined[key] = refs
+	}
+	r.Unlock()
+
+	if len(refs) == 0 {
+		if len(before) > 0 {
+			r.errors.printf("Template %s: undefined templates resolved.\n", key)
+		}
+		return
+	}
+	if sameRefs(before, refs) {
+		return
+	}
+	for _, w := range refWarnings(key, refs) {
+		r.errors.printf("Warning: %s\n", w)
+	}
+}
+
+func sameRefs(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for ref, file := range a {
+		if f, ok := b[ref]; !ok || f != file {
+			return false
+		}
+	}
+	return true
+}
+
+// refWarnings describes the undefined templates refs of the page key.
+func refWarnings(key string, refs map[string]string) []string {
+	var warnings []string
+	for ref, file := range refs {
+		warnings = append(warnings, fmt.Sprintf(
+			"template %s: {{template %q}} in %s is not defined (best effort check)",
+			key, ref, file))
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// RefWarnings describes the templates invoked with {{template}} that the
+// pages invoking them don't define, as of their last parse. They're sent
+// to the clients with every reload too.
+func (r *Reloader) RefWarnings() []string {
+	r.RLock()
+	defer r.RUnlock()
+	var warnings []string
+	for key, refs := range r.undefined {
+		warnings = append(warnings, refWarnings(key, refs)...)
+	}
+	sort.Strings(warnings)
+	return warnings
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUndefinedRefs(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"index.html": `{{template "_nav.html" .}}{{template "footer"}}{{define "footer"}}<footer>{{end}}`,
+		"_nav.html":  `<nav>one</nav>`,
+	})
+	if w := r.RefWarnings(); len(w) > 0 {
+		t.Fatalf("RefWarnings() = %v for a complete set", w)
+	}
+	var log lockedBuffer
+	r.errors.out = &log
+
+	// The define is renamed, but not the reference to it.
+	msg := edit(t, dir, watchers, map[string]string{
+		"index.html": `{{template "_nav.html" .}}{{template "footer"}}{{define "page-footer"}}<footer>{{end}}`,
+	})
+	want := `template index: {{template "footer"}} in index.html is not defined (best effort check)`
+	if len(msg.Warnings) != 1 || msg.Warnings[0] != want {
+		t.Errorf("message warnings = %q, want %q", msg.Warnings, want)
+	}
+	if w := r.RefWarnings(); len(w) != 1 || w[0] != want {
+		t.Errorf("RefWarnings() = %q, want %q", w, want)
+	}
+	if got := log.String(); !strings.Contains(got, "Warning: "+want) {
+		t.Errorf("logged %q, want the warning", got)
+	}
+
+	msg = edit(t, dir, watchers, map[string]string{
+		"index.html": `{{template "_nav.html" .}}{{template "page-footer"}}{{define "page-footer"}}<footer>{{end}}`,
+	})
+	if len(msg.Warnings) > 0 {
+		t.Errorf("message warnings = %q once fixed, want none", msg.Warnings)
+	}
+	if w := r.RefWarnings(); len(w) > 0 {
+		t.Errorf("RefWarnings() = %q once fixed, want none", w)
+	}
+	if got := log.String(); !strings.Contains(got, "Template index: undefined templates resolved.") {
+		t.Errorf("logged %q, want the warning cleared", got)
+	}
+}
@@ -1,109 +1,755 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"html/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
-	"sync/atomic"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
+// Reloader keeps the templates of its roots parsed as their files change.
+//
+// Templates are stored under one key however their file was found, at
+// startup or through an event: the root's Prefix followed by the file's
+// path relative to the root, slash separated and without extension. So with
+// the root "templates" or "./templates", both "templates/admin/users.html"
+// and "./templates/admin/users.html" are "admin/users", and
+// "templates/index.html" is "index", which Get and the server look up.
 type Reloader struct {
-	templates map[string]*template.Template
+	templates templateMap
+	// sources maps template keys to the file they were parsed from.
+	sources map[string]string
+	// partials maps the keys of partials to the file providing them, see
+	// DefaultPartialPrefix.
+	partials map[string]string
+	// loaded records when each template key was last parsed.
+	loaded map[string]time.Time
+	// versions counts the successful parses of each template key, see
+	// Version.
+	versions map[string]uint64
+	// added holds the templates added with AddTemplate, which templates
+	// parsed from files take precedence over.
+	added map[string]Template
+	// shadowed maps the keys of added templates to the file found
+	// overriding them, once that was reported.
+	shadowed map[string]string
+	// globSets are parsed into sets, see WithGlobSet.
+	globSets []globSet
+	sets     map[string]Template
+	// parseErrors holds why the templates still serving their last good
+	// version failed to parse since, see ParseErrors.
+	parseErrors map[string]error
+	// deps maps the keys of pages to the partials they include, see
+	// Dependencies.
+	deps map[string][]string
 
-	*fsnotify.Watcher
+	// PartialPrefix is the file name prefix marking partials. Set it to ""
+	// to treat every template as a page.
+	PartialPrefix string
+	// PartialDirs lists directories whose templates are all partials, like
+	// "layouts" and "partials", as key prefixes. Pages are parsed after
+	// the partials, so a page's {{define}} fills in a layout's {{block}}.
+	PartialDirs []string
+
+	// Debounce is how long a file has to stay quiet before its changes are
+	// handled, DefaultDebounce if zero.
+	Debounce time.Duration
+	// Coalesce is how long settled changes are collected, to be reloaded
+	// together and announced with a single message. DefaultCoalesce if
+	// zero.
+	Coalesce time.Duration
+	// Settle is how often changed files are checked for still being
+	// written before they're parsed, DefaultSettle if zero.
+	Settle time.Duration
+	// SlowParse is how long parsing a template may take before a warning
+	// is logged, DefaultSlowParse if zero.
+	SlowParse time.Duration
+	// ParseWorkers is how many pages are parsed at once when many have
+	// to be, like on a full scan or after a partial changes. It defaults
+	// to GOMAXPROCS.
+	ParseWorkers int
+	// RenderTimeout limits how long rendering a page may take before it's
+	// abandoned with 503 Service Unavailable. It is unlimited if zero.
+	RenderTimeout time.Duration
+
+	// Ignore lists filepath.Match patterns of file names whose changes are
+	// dropped without reloading anything. It defaults to DefaultIgnore.
+	Ignore []string
+	// Exclude lists glob patterns of paths, relative to their root, that
+	// are neither watched nor parsed. See WithExclude.
+	Exclude []string
+
+	// roots are the template directories passed to New or AddDir. They
+	// are re-added whenever the watcher has to be recreated. See rootList.
+	roots   []Root
+	rootsMu sync.RWMutex
+	// static are directories holding assets rather than templates. Changes
+	// to them reload the page without parsing anything.
+	static  []string
+	stats   Stats
+	errors  errorLog
+	assets  assetCache
+	usage   usageLog
+	changes changeQueue
+	samples samples
+	// parseTimes are how long templates took to parse.
+	parseTimes parseTimes
+
+	// Webhook, when set, is served by Handler. Add it with AddSource so
+	// its changes get reloaded.
+	Webhook *Webhook
+
+	// pollInterval, when set, replaces filesystem notifications with
+	// polling, see WithPolling.
+	pollInterval time.Duration
+	// pollers poll each root with WithPolling, so RemoveDir can stop
+	// them.
+	pollers map[string]*Poller
+	// hybridInterval, when set, polls the directories notifications don't
+	// work for, see WithHybrid.
+	hybridInterval time.Duration
+	// probes holds a channel for each probe file being waited for.
+	probes sync.Map
+
+	// unwatched are the directories polled because the system ran out of
+	// watches for them.
+	unwatched map[string]bool
+
+	// eventFilter decides which changes reload anything, see
+	// WithEventFilter.
+	eventFilter func(fsnotify.Event) bool
+
+	// done is closed once the context given to Watch is done or the
+	// Reloader is closed, see stop.
+	done     chan struct{}
+	stopOnce sync.Once
+	// cancel cancels the context Watch runs with.
+	cancel context.CancelFunc
+	// closed is set by Close.
+	closed    bool
+	closeOnce sync.Once
+
+	// recorder writes the watcher's events down, see WithRecording.
+	recorder *recorder
+	// parseHook stands in for reading and parsing changed files, see
+	// WithParseHook.
+	parseHook func(name string) error
+
+	// userFuncs are the functions given to WithFuncs and Funcs.
+	userFuncs map[string]interface{}
+
+	// left and right delimit actions, see WithDelims.
+	left, right string
+
+	// collisions decides which file provides a key, see WithCollisions.
+	collisions Collisions
+
+	// keyFunc derives template keys, see WithKeyFunc.
+	keyFunc func(root, path string) string
+	// keyIndex caches the files providing each key for resolveAny.
+	keyIndex keyIndex
+
+	// extensions of template files, see WithExtensions.
+	extensions []string
+	// engines parse files by extension, see WithEngine.
+	engines map[string]Engine
+	// buffers are reused to render pages into.
+	buffers bufferPool
+	// bases are the copies of templates request funcs are applied to, see
+	// RenderRequest.
+	bases map[string]*cloneBase
+	// outlines hold what the parse trees of each page's set told before
+	// it could be executed, see outline.
+	outlines map[string]setOutline
+	// loaders provide templates too, see WithLoader.
+	loaders []*loaderState
+	// preprocessors are given to WithPreprocessor.
+	preprocessors []func(path string, src []byte) ([]byte, error)
+	// minify is set by WithMinify.
+	minify bool
+	// templateOptions are set on every template, see WithTemplateOptions.
+	templateOptions []string
+	// layout is the template key Markdown pages render through, see
+	// WithMarkdown.
+	layout string
+	// errorTemplates are the templates rendered for error statuses, see
+	// WithErrorTemplate.
+	errorTemplates map[int]string
+	// contentTypes are given to WithContentType by key or extension.
+	contentTypes map[string]string
+	// pageLayout is the template key pages render through, see WithLayout.
+	pageLayout string
+	// locales are the translations given to WithLocales.
+	locales *locales
+	// sprig are the sprig functions, see WithSprig.
+	sprig map[string]interface{}
+	// undefined maps pages to the templates they invoke without defining
+	// them, and the files invoking them, see RefWarnings.
+	undefined map[string]map[string]string
+
+	// followSymlinks descends into symlinked directories, see
+	// WithFollowSymlinks.
+	followSymlinks bool
+
+	// liveignore holds the rules of each root's .liveignore file.
+	liveignore map[string]ignoreFile
+
+	// dirs are the directories being watched, and those removed since,
+	// see watching.
+	dirs map[string]bool
+
+	// fingerprints of every directory below the roots as of the last scan,
+	// and the files that were in them.
+	fingerprints map[string]fingerprint
+	scanned      map[string][]scannedFile
+
+	// newWatcher creates the watcher, see WithWatcher.
+	newWatcher func() (Watcher, error)
+
+	Watcher
 	*sync.RWMutex
 }
 
-func (r *Reloader) Get(name string) *template.Template {
+// ErrTemplateNotFound is returned by Get for names no template has.
+var ErrTemplateNotFound = errors.New("template not found")
+
+// Get returns the template name, parsing it from the file providing it if
+// it wasn't loaded yet. It fails with an error wrapping ErrTemplateNotFound
+// if there's no such template, or with the parse error if its file never
+// parsed.
+func (r *Reloader) Get(name string) (Template, error) {
+	if tmpl := r.lookup(name); tmpl != nil {
+		return tmpl, nil
+	}
+	return r.load(name)
+}
+
+// Names returns the sorted names of the templates Get has loaded, including
+// those added with AddTemplate and glob sets.
+func (r *Reloader) Names() []string {
+	r.RLock()
+	defer r.RUnlock()
+	seen := map[string]bool{}
+	for _, m := range []map[string]Template{r.templates.load(), r.added, r.sets} {
+		for name := range m {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TemplateInfo describes a template, see Describe.
+type TemplateInfo struct {
+	Key string `json:"key"`
+	// Path is the file the template was parsed from, empty for templates
+	// not parsed from a file, like those added with AddTemplate.
+	Path string `json:"path,omitempty"`
+	// Loaded is when the template was last parsed.
+	Loaded time.Time `json:"loaded,omitempty"`
+	// Err is why the template failed to parse since, if it's serving its
+	// last good version.
+	Err string `json:"error,omitempty"`
+}
+
+// Describe returns the templates Names lists, with where each comes from,
+// when it was last parsed, and why it failed to parse since, if it did.
+func (r *Reloader) Describe() []TemplateInfo {
+	names := r.Names()
+	r.RLock()
+	defer r.RUnlock()
+	infos := make([]TemplateInfo, len(names))
+	for i, name := range names {
+		infos[i] = TemplateInfo{
+			Key:    name,
+			Path:   r.sources[name],
+			Loaded: r.loaded[name],
+		}
+		if err := r.parseErrors[name]; err != nil {
+			infos[i].Err = err.Error()
+		}
+	}
+	return infos
+}
+
+// lookup returns the template name if it's loaded. Templates parsed from
+// files are found without locking.
+func (r *Reloader) lookup(name string) Template {
+	if t, ok := r.templates.get(name); ok {
+		return t
+	}
 	r.RLock()
-	defer r.Unlock()
-	if t, ok := r.templates[name]; ok {
+	defer r.RUnlock()
+	if t, ok := r.added[name]; ok {
+		return t
+	}
+	if t, ok := r.sets[name]; ok {
 		return t
 	}
 	return nil
 }
 
-// New returns an initialized Reloader that starts watching the given
-// directories for all events.
-func New(dirs ...string) *Reloader {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		panic(err)
+// store makes tmpl, parsed from path, the template for key.
+func (r *Reloader) store(key, path string, tmpl Template) {
+	o := outline(tmpl)
+	r.Lock()
+	// The copy request funcs are applied to is taken on first use, see
+	// cloneBase.
+	delete(r.bases, key)
+	if _, ok := r.added[key]; ok && r.sources[key] != path {
+		r.errors.printf("Warning: %s now provides template %s, replacing the one added.\n",
+			path, key)
+	}
+	if r.outlines == nil {
+		r.outlines = map[string]setOutline{}
+	}
+	r.outlines[key] = o
+	r.templates.set(key, tmpl)
+	r.sources[key] = path
+	r.loaded[key] = time.Now()
+	r.versions[key]++
+	delete(r.parseErrors, key)
+	r.Unlock()
+	r.track(key)
+}
+
+// Version returns the version of the template key, which starts at 1 and
+// goes up each time the template parses successfully, and false if there
+// is no such template. A template whose file is removed loses its version,
+// so it starts at 1 again if the file comes back.
+func (r *Reloader) Version(key string) (uint64, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	v, ok := r.versions[key]
+	return v, ok
+}
+
+// Loaded returns when the template key was last parsed.
+func (r *Reloader) Loaded(key string) time.Time {
+	r.RLock()
+	defer r.RUnlock()
+	return r.loaded[key]
+}
+
+// New returns an initialized Reloader that starts watching the roots among
+// options and every directory below them for all events. Templates with the
+// same key in several roots are taken from the last one, so later roots can
+// override templates of earlier ones. If the watcher can't be created,
+// Watch keeps trying to recreate it like one that died.
+func New(options ...Option) *Reloader {
+	r := &Reloader{
+		dirs:          map[string]bool{},
+		sources:       map[string]string{},
+		partials:      map[string]string{},
+		loaded:        map[string]time.Time{},
+		versions:      map[string]uint64{},
+		PartialPrefix: DefaultPartialPrefix,
+		Ignore:        DefaultIgnore,
+		newWatcher:    newFSWatcher,
+		done:          make(chan struct{}),
+		RWMutex:       &sync.RWMutex{},
+	}
+	for _, option := range options {
+		option.apply(r)
+	}
+	watcher, watchErr := r.newWatcher()
+	if watchErr != nil {
+		// A closed watcher is taken for a dead one, which Watch keeps
+		// trying to recreate.
+		fmt.Println("Unable to watch templates:", watchErr)
+		closed := NewFakeWatcher()
+		closed.Close()
+		watcher = closed
+	}
+	r.Watcher = watcher
+	for i := range r.roots {
+		r.roots[i] = r.setUp(r.roots[i])
+	}
+	for _, l := range r.loaders {
+		l.root = r.setUp(l.root)
+	}
+	for _, root := range r.rootList() {
+		if !root.file {
+			r.loadIgnore(root.Path)
+		}
+	}
+
+	for _, root := range r.rootList() {
+		if r.pollInterval > 0 || watchErr != nil {
+			break
+		}
+		if root.file {
+			if err := watcher.Add(root.dir()); err != nil {
+				fmt.Println("Unable to watch templates:", err)
+			}
+			continue
+		}
+		if err := r.watchTree(watcher, root.Path); err != nil {
+			fmt.Println("Unable to watch templates:", err)
+		}
+	}
+	if r.locales != nil && r.pollInterval == 0 && watchErr == nil {
+		if err := watcher.Add(r.locales.dir); err != nil {
+			fmt.Println("Unable to watch locales:", err)
+		}
+	}
+	return r
+}
+
+// WatchStatic starts watching a directory of static assets and the
+// directories below it.
+func (r *Reloader) WatchStatic(dir string) error {
+	// Excludes are relative to the static directory, so it has to be
+	// known before walking it.
+	r.static = append(r.static, dir)
+	if r.pollInterval > 0 {
+		return nil
 	}
+	if err := r.watchTree(r.Watcher, dir); err != nil {
+		r.static = r.static[:len(r.static)-1]
+		return err
+	}
+	return nil
+}
 
-	for _, path := range dirs {
-		watcher.Add(path)
+// StaticFile looks name up in the static directories and returns the path
+// of the first match.
+func (r *Reloader) StaticFile(name string) (string, bool) {
+	for _, dir := range r.static {
+		path := filepath.Join(dir, filepath.Clean("/"+filepath.FromSlash(name)))
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
 	}
+	return "", false
+}
 
-	return &Reloader{
-		Watcher: watcher,
-		RWMutex: &sync.RWMutex{},
+func (r *Reloader) isStatic(name string) bool {
+	for _, dir := range r.static {
+		if isWithin(name, dir) {
+			return true
+		}
 	}
+	return false
 }
 
-func AddClamp(f uint8) uint8 {
-	return (f + 1) % 255
+// isWithin reports whether path is dir or lies somewhere below it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." &&
+		!strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
 
-func (r *Reloader) Watch() {
+// Watch processes watcher events in the background until ctx is done. If
+// the watcher dies it is recreated, see recoverWatcher. With WithPolling the
+// directories are polled instead.
+//
+// Once ctx is done, changes are no longer handled and the sources added
+// with AddSource are closed. The templates loaded so far stay available.
+func (r *Reloader) Watch(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	context.AfterFunc(ctx, r.stop)
+	r.Lock()
+	r.cancel = cancel
+	r.Unlock()
+	for _, l := range r.loaders {
+		go r.watchLoader(l)
+	}
+	if r.pollInterval > 0 {
+		for _, path := range r.rootPaths() {
+			r.pollRoot(path)
+		}
+		if len(r.static) > 0 {
+			r.AddSource(newPoller(r.pollInterval, r.static, r.walkOptions()))
+		}
+		if r.locales != nil {
+			r.AddSource(newPoller(r.pollInterval, []string{r.locales.dir}, r.walkOptions()))
+		}
+		return
+	}
 	go func() {
+		restarts := 0
 		for {
-			select {
-			case evt := <-r.Watcher.Events:
-				if eventIsWanted(evt.Op) {
-					fmt.Printf("File: %s Event: %s. Hot reloading.\n",
-						evt.Name, evt.String())
-
-					if err := r.reload(evt.Name); err != nil {
-						fmt.Println(err)
-					}
-
-					atomic.AddUint64(&versionCounter, 1)
-					broadcastCond.Broadcast()
-				}
-			case err := <-r.Watcher.Errors:
-				fmt.Println(err)
+			started := time.Now()
+			r.watchEvents(ctx)
+			if ctx.Err() != nil || !r.recoverWatcher(ctx, started, &restarts) {
+				return
 			}
 		}
 	}()
+	if r.hybridInterval > 0 {
+		go r.pollUnnotified()
+	}
+}
+
+// watchEvents processes events until ctx is done, the watcher's channels
+// are closed, which only happens once the watcher has died, or it reports
+// an error, which is taken as a sign its watches are gone.
+func (r *Reloader) watchEvents(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt, ok := <-r.Watcher.Events():
+			if !ok {
+				return
+			}
+			r.record(evt)
+			if r.probed(evt.Name) {
+				continue
+			}
+			if !r.watchDirs(evt) {
+				r.change(ChangeEvent{evt.Name, evt.Op})
+			}
+		case err, ok := <-r.Watcher.Errors():
+			if !ok {
+				return
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				r.overflowed()
+				continue
+			}
+			r.errors.print(&ChangeError{Err: err})
+			return
+		}
+	}
+}
+
+// handleChanges reloads what the changes evts affect and tells the clients
+// with a single message, preceded by an error if a template failed to
+// parse.
+func (r *Reloader) handleChanges(evts []ChangeEvent) {
+	var files, templates, paths []string
+	var failed *brokenTemplate
+	versions := map[string]uint64{}
+	everyPage := false
+	for _, evt := range evts {
+		if filepath.Base(evt.Path) == liveignoreName {
+			r.reloadIgnore(evt.Path)
+			continue
+		}
+		if r.isIgnored(evt.Path) {
+			debugf("File: %s Event: %s. Ignored.\n", evt.Path, evt.Op)
+			continue
+		}
+		r.invalidateAsset(evt.Path)
+		if !r.eventIsWanted(evt) || r.isRootSibling(evt.Path) {
+			continue
+		}
+		if r.isLocale(evt.Path) {
+			fmt.Printf("Locale: %s Event: %s. Reloading.\n", evt.Path, evt.Op)
+			for _, err := range r.loadLocales() {
+				r.errors.print(err)
+			}
+			files = append(files, evt.Path)
+			everyPage = true
+			continue
+		}
+		if r.isStatic(evt.Path) || r.isAsset(evt.Path) {
+			fmt.Printf("Asset: %s Event: %s. Reloading.\n", evt.Path, evt.Op)
+			files = append(files, evt.Path)
+			everyPage = true
+			continue
+		}
+		if _, _, ok := r.fileRoot(evt.Path); !ok {
+			debugf("File: %s is not under any root; ignored.\n", evt.Path)
+			continue
+		}
+		fmt.Printf("File: %s Event: %s. Hot reloading.\n", evt.Path, evt.Op)
+		if err := r.reloadChanged(evt); err != nil {
+			r.errors.print(&ChangeError{evt.Path, evt.Op, err})
+			// Pages keep showing the last good version of a broken
+			// template, so there's nothing to reload them for until
+			// it's fixed.
+			var broken *brokenTemplate
+			if errors.As(err, &broken) {
+				if failed == nil {
+					failed = broken
+				}
+				continue
+			}
+		} else if key, ok := r.templateKey(evt.Path); ok {
+			if v, ok := r.Version(key); ok {
+				versions[key] = v
+			}
+		}
+		files = append(files, evt.Path)
+		templates = append(templates, evt.Path)
+		if affected := r.affectedPages(evt.Path); affected != nil {
+			paths = append(paths, affected...)
+		} else {
+			everyPage = true
+		}
+	}
+	// The other files of the batch are reloaded regardless.
+	if failed != nil {
+		publish(Message{Type: MessageError, Error: failed.Error()})
+	}
+	if len(files) == 0 {
+		return
+	}
+	if isStrict() {
+		for _, name := range templates {
+			if err := r.dryRun(name); err != nil {
+				r.errors.print(err)
+				publish(Message{Type: MessageError, Error: err.Error()})
+				return
+			}
+		}
+	}
+	msg := Message{Type: MessageReload, Files: files, Warnings: r.RefWarnings()}
+	if len(versions) > 0 {
+		msg.Templates = versions
+	}
+	if !everyPage {
+		sort.Strings(paths)
+		msg.Paths = slices.Compact(paths)
+	}
+	publish(msg)
 }
 
-func eventIsWanted(op fsnotify.Op) bool {
-	switch op {
-	case fsnotify.Write, fsnotify.Create:
+// reloadChanged reloads the file changed by evt, or has the hook given to
+// WithParseHook parse it.
+func (r *Reloader) reloadChanged(evt ChangeEvent) error {
+	if r.parseHook != nil {
+		return r.stubParse(evt.Path)
+	}
+	if _, ok := r.templateKey(evt.Path); ok &&
+		(evt.Has(fsnotify.Remove) || evt.Has(fsnotify.Rename)) {
+		awaitReplacement(evt.Path)
+	}
+	return r.reload(evt.Path)
+}
+
+// DefaultIgnore ignores Go sources, so running the server with "go run"
+// inside the directory it watches doesn't reload pages on every edit of its
+// own code, as well as EditorIgnore.
+var DefaultIgnore = append([]string{"*.go"}, EditorIgnore...)
+
+// EditorIgnore matches the swap, backup, lock and temporary files editors
+// write next to the files being edited, like vim's "4913" probe file.
+var EditorIgnore = []string{
+	"*.swp", "*.swo", "*.swx", "*~", ".#*", "#*#", "*.tmp", "4913",
+}
+
+// isIgnored reports whether name matches one of the Ignore patterns, is
+// excluded or below its root's MaxDepth, or is not a template of a root
+// listing its extensions.
+func (r *Reloader) isIgnored(name string) bool {
+	if r.isExcluded(name) || r.isForeign(name) || r.tooDeep(filepath.Dir(name)) {
 		return true
-	default:
-		return false
 	}
+	base := filepath.Base(name)
+	for _, pattern := range r.Ignore {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
 }
 
-func (r *Reloader) reload(name string) error {
+// eventIsWanted reports whether evt should reload anything, as decided by
+// the filter given to WithEventFilter or DefaultEventFilter.
+func (r *Reloader) eventIsWanted(evt ChangeEvent) bool {
+	filter := r.eventFilter
+	if filter == nil {
+		filter = DefaultEventFilter
+	}
+	return filter(fsnotify.Event{Name: evt.Path, Op: evt.Op})
+}
 
-	// Just for example purposes, and sssuming 'index.gohtml' is in the
-	// same directory as this file.
-	if name == TemplatePath+"reload.go" {
-		return nil
+// DefaultEventFilter wants events changing a file's contents. Events may
+// combine several operations, like Write|Chmod, and are wanted if any of
+// them is.
+func DefaultEventFilter(evt fsnotify.Event) bool {
+	return evt.Has(fsnotify.Write) || evt.Has(fsnotify.Create) ||
+		evt.Has(fsnotify.Remove) || evt.Has(fsnotify.Rename)
+}
+
+func (r *Reloader) reload(name string) error {
+	// Events name files as "./index.html" when watching "./", while the
+	// startup scan finds "index.html". Both must map to the same key.
+	name = filepath.Clean(name)
+	if err := r.reloadGlobSets(name); err != nil {
+		r.errors.print(err)
 	}
 
-	if len(name) >= len(TemplateExt) &&
-		name[len(name)-len(TemplateExt):] == TemplateExt {
+	if key, ok := r.templateKey(name); ok {
+		// Whichever file provides the key gets parsed, so editing an
+		// overridden file leaves the override in place, and removing an
+		// override falls back to the file it was overriding.
+		path, found := r.resolve(key)
+		if found {
+			r.collided(key, path, name)
+		}
 
-		tmpl := template.Must(template.ParseFiles(name))
+		if r.isPartial(key) {
+			r.Lock()
+			_, had := r.partials[key]
+			if found {
+				r.partials[key] = path
+			} else {
+				delete(r.partials, key)
+			}
+			r.Unlock()
+			if had && !found {
+				fmt.Printf("Partial %s evicted; %s was removed.\n", key, name)
+			}
+			// Only the pages including an edited partial change, while
+			// one that's added or removed may be referenced by any, and
+			// the layout wraps them all.
+			if had && found && !r.isLayout(key) {
+				return r.reloadDependents(key)
+			}
+			return r.reloadPages()
+		}
 
-		// Gather what would be the key in our template map.
-		// 'name' is in the format: "path/identifier.extension",
-		// so trim the 'path/' and the '.extension' to get the
-		// name (minus new extension) used inside of our map.
-		key := name[0 : len(name)-len(TemplateExt)]
+		if !found {
+			r.Lock()
+			had := r.templates.delete(key)
+			delete(r.sources, key)
+			delete(r.loaded, key)
+			delete(r.versions, key)
+			delete(r.parseErrors, key)
+			delete(r.bases, key)
+			delete(r.outlines, key)
+			r.Unlock()
+			r.untrack(key)
+			if had {
+				fmt.Printf("Template %s evicted; %s was removed.\n", key, name)
+			}
+			return nil
+		}
 
-		r.Lock()
-		r.templates[key] = tmpl
-		r.Unlock()
+		// A template failing to parse keeps serving its last good version
+		// until the file is fixed.
+		tmpl, err := r.parseSaved(path)
+		if err != nil {
+			return r.broken(key, err)
+		}
+		r.store(key, path, tmpl)
 		return nil
 	}
 
-	return fmt.Errorf("Unable to reload file %s", name)
+	if _, _, ok := r.fileRoot(name); !ok {
+		return fmt.Errorf("File %s is not under any template root %v",
+			name, r.rootPaths())
+	}
 
+	// Anything else in the roots, like stylesheets, still reloads the page
+	// but has nothing to parse.
+	debugf("File: %s is not a template; nothing to parse.\n", name)
+	return nil
 }
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// writeFiles writes files, keyed by their slash separated path, below dir.
+func writeFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// fakeWatchers hands a Reloader a new FakeWatcher whenever it asks for
+// one, keeping them all.
+type fakeWatchers struct {
+	mu  sync.Mutex
+	all []*FakeWatcher
+}
+
+func (f *fakeWatchers) option() Option {
+	return WithWatcher(func() (Watcher, error) {
+		w := NewFakeWatcher()
+		f.mu.Lock()
+		f.all = append(f.all, w)
+		f.mu.Unlock()
+		return w, nil
+	})
+}
+
+// count returns how many watchers were handed out.
+func (f *fakeWatchers) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.all)
+}
+
+// last returns the watcher handed out last.
+func (f *fakeWatchers) last() *FakeWatcher {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.all[len(f.all)-1]
+}
+
+// newTestReloader returns a Reloader over a temporary directory holding
+// files, see startTestReloader.
+func newTestReloader(t testing.TB, files map[string]string, options ...Option) (*Reloader, string, *fakeWatchers) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	r, watchers := startTestReloader(t, append([]Option{Root{Path: dir}}, options...)...)
+	return r, dir, watchers
+}
+
+// startTestReloader returns a Reloader with options, scanned and watching
+// with FakeWatchers, which handles changes within milliseconds. It is
+// closed once the test ends.
+func startTestReloader(t testing.TB, options ...Option) (*Reloader, *fakeWatchers) {
+	t.Helper()
+	watchers := &fakeWatchers{}
+	r := New(append([]Option{watchers.option()}, options...)...)
+	r.Debounce = testDelay
+	r.Coalesce = testDelay
+	r.Settle = testDelay
+	r.Scan()
+	ctx, cancel := context.WithCancel(context.Background())
+	r.Watch(ctx)
+	t.Cleanup(func() {
+		cancel()
+		r.Close()
+	})
+	return r, watchers
+}
+
+// setFlag sets the flag to value until the test ends.
+func setFlag[T any](t testing.TB, flag *T, value T) {
+	t.Helper()
+	old := *flag
+	*flag = value
+	t.Cleanup(func() { *flag = old })
+}
+
+// waitFor fails the test unless cond turns true within testTimeout.
+func waitFor(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// published waits for a message to be published after the version since,
+// and returns the last one.
+func published(t testing.TB, since Version) Message {
+	t.Helper()
+	waitFor(t, "a message", func() bool { return since.Before(currentVersion()) })
+	broadcastCond.L.Lock()
+	defer broadcastCond.L.Unlock()
+	return lastMessage
+}
+
+// edit writes files below dir and reports them written through the last
+// watcher, returning the message the changes were published with. Their
+// modification times are set from the clock, so edits in quick succession
+// aren't taken for one under coarse filesystem timestamps.
+func edit(t testing.TB, dir string, watchers *fakeWatchers, files map[string]string) Message {
+	t.Helper()
+	since := currentVersion()
+	writeFiles(t, dir, files)
+	for name := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			t.Fatal(err)
+		}
+		watchers.last().Send(path, fsnotify.Write)
+	}
+	return published(t, since)
+}
+
+// execute returns the output of the template key executed with data.
+func execute(t testing.TB, r *Reloader, key string, data interface{}) string {
+	t.Helper()
+	tmpl, err := r.Get(key)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", key, err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		t.Fatalf("executing %s: %v", key, err)
+	}
+	return out.String()
+}
+
+func TestGetParsesOnDemand(t *testing.T) {
+	r, _, _ := newTestReloader(t, map[string]string{
+		"index.html":       "index",
+		"admin/users.html": "users",
+	})
+	for _, key := range []string{"index", "admin/users"} {
+		if _, err := r.Get(key); err != nil {
+			t.Errorf("Get(%q): %v", key, err)
+		}
+	}
+	if _, err := r.Get("missing"); err == nil {
+		t.Error("Get(missing) succeeded")
+	}
+}
+
+func TestGoSourcesIgnoredByDefault(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "index"})
+	if !r.isIgnored(filepath.Join(dir, "main.go")) {
+		t.Fatal("main.go isn't ignored by default")
+	}
+	// Only the template's change comes through.
+	msg := edit(t, dir, watchers, map[string]string{
+		"main.go":    "package main",
+		"index.html": "index2",
+	})
+	if want := []string{filepath.Join(dir, "index.html")}; !slices.Equal(msg.Files, want) {
+		t.Errorf("Files = %v, want %v", msg.Files, want)
+	}
+}
+
+func TestGoSourcesReloadWithoutIgnoreRule(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, nil)
+	r.Ignore = EditorIgnore
+	if r.isIgnored(filepath.Join(dir, "main.go")) {
+		t.Fatal("main.go is ignored without a rule for it")
+	}
+	msg := edit(t, dir, watchers, map[string]string{"main.go": "package main"})
+	if want := []string{filepath.Join(dir, "main.go")}; !slices.Equal(msg.Files, want) {
+		t.Errorf("Files = %v, want %v", msg.Files, want)
+	}
+}
+
+func TestRemoveEvictsTemplate(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"index.html": "index",
+		"about.html": "about",
+	})
+	if _, err := r.Get("about"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "about.html")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	since := currentVersion()
+	watchers.last().Send(path, fsnotify.Remove)
+	if msg := published(t, since); msg.Type != MessageReload {
+		t.Errorf("got %s message, want %s", msg.Type, MessageReload)
+	}
+	if _, err := r.Get("about"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Get(about) after removal: %v, want ErrTemplateNotFound", err)
+	}
+	if slices.Contains(r.Names(), "about") {
+		t.Errorf("Names() = %v still lists about", r.Names())
+	}
+}
+
+func TestDefaultEventFilter(t *testing.T) {
+	tests := []struct {
+		op   fsnotify.Op
+		want bool
+	}{
+		{fsnotify.Write, true},
+		{fsnotify.Create, true},
+		{fsnotify.Remove, true},
+		{fsnotify.Rename, true},
+		{fsnotify.Write | fsnotify.Chmod, true},
+		{fsnotify.Create | fsnotify.Write, true},
+		{fsnotify.Remove | fsnotify.Chmod, true},
+		{fsnotify.Chmod, false},
+		{0, false},
+	}
+	for _, tt := range tests {
+		evt := fsnotify.Event{Name: "index.html", Op: tt.op}
+		if got := DefaultEventFilter(evt); got != tt.want {
+			t.Errorf("DefaultEventFilter(%v) = %v, want %v", tt.op, got, tt.want)
+		}
+	}
+}
+
+func TestEventFilterOption(t *testing.T) {
+	r, _, _ := newTestReloader(t, nil, WithEventFilter(func(evt fsnotify.Event) bool {
+		return evt.Has(fsnotify.Chmod)
+	}))
+	if !r.eventIsWanted(ChangeEvent{"index.html", fsnotify.Chmod}) ||
+		r.eventIsWanted(ChangeEvent{"index.html", fsnotify.Write}) {
+		t.Error("the filter given to WithEventFilter isn't used")
+	}
+}
+
+func TestEditorFilesIgnored(t *testing.T) {
+	editorFiles := []string{".index.html.swp", "index.html.swo", "index.html~",
+		".#index.html", "#index.html#", "index.html.tmp", "4913"}
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "index"})
+	names := r.Names()
+	since := currentVersion()
+	for _, name := range editorFiles {
+		writeFiles(t, dir, map[string]string{name: "junk"})
+		watchers.last().Send(filepath.Join(dir, name), fsnotify.Write)
+	}
+	time.Sleep(10 * testDelay)
+	if v := currentVersion(); v != since {
+		t.Errorf("editor files published %d messages", v.Since(since))
+	}
+	if !slices.Equal(r.Names(), names) {
+		t.Errorf("Names() = %v, want %v", r.Names(), names)
+	}
+
+	// Only vim's probe is ignored, not other files with numbers as names.
+	for _, name := range []string{"2024", "2024.html", "0404"} {
+		if r.isIgnored(filepath.Join(dir, name)) {
+			t.Errorf("%s is ignored", name)
+		}
+	}
+
+	// The list can be extended or dropped.
+	r, dir, _ = newTestReloader(t, nil)
+	r.Ignore = append(slices.Clone(EditorIgnore), "*.bak")
+	if !r.isIgnored(filepath.Join(dir, "index.html.bak")) {
+		t.Error("index.html.bak isn't ignored with *.bak added")
+	}
+	r.Ignore = nil
+	if r.isIgnored(filepath.Join(dir, "4913")) {
+		t.Error("4913 is ignored without any patterns")
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"sync"
+	texttemplate "text/template"
+)
+
+// RenderRequest renders the template name with data in response to req,
+// like the pages served by the Reloader, with funcs available to it for
+// this request only, like a csrfField or nonce built from req. The
+// functions must also be given to WithFuncs, possibly as stubs, so
+// templates calling them parse.
+func (r *Reloader) RenderRequest(w http.ResponseWriter, req *http.Request, name string, data interface{}, funcs map[string]interface{}) error {
+	return render(r, w, req, name, data, funcs, "")
+}
+
+// pageFuncs returns the request funcs of the pages the Reloader serves, see
+// LocaleFuncs and ClientFuncs.
+func (r *Reloader) pageFuncs(w http.ResponseWriter, req *http.Request) map[string]interface{} {
+	funcs := map[string]interface{}{}
+	for name, fn := range r.LocaleFuncs(req) {
+		funcs[name] = fn
+	}
+	for name, fn := range r.ClientFuncs(w, req) {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// takesFuncs reports whether request funcs can be applied to tmpl.
+func takesFuncs(tmpl Template) bool {
+	switch t := tmpl.(type) {
+	case *htmltemplate.Template, *texttemplate.Template:
+		return true
+	case blockTemplate:
+		return takesFuncs(t.Template)
+	}
+	return false
+}
+
+// cloneBase is an unexecuted copy of a template, which request funcs are
+// applied to clones of. html/template can't clone templates once they've
+// executed. It's taken on the first request with funcs, so templates only
+// ever rendered without them aren't copied at all.
+type cloneBase struct {
+	// of is the template the base is a copy of, so a reload replacing it
+	// replaces the base too.
+	of   Template
+	once sync.Once
+	base Template
+	err  error
+}
+
+// withFuncs returns a copy of tmpl, the template key, with funcs applied,
+// or tmpl itself if there are none.
+func (r *Reloader) withFuncs(key string, tmpl Template, funcs map[string]interface{}) (Template, error) {
+	if len(funcs) == 0 {
+		return tmpl, nil
+	}
+	// Pages wrapped in the layout get the funcs applied to the template
+	// set they execute the layout of, see WithLayout.
+	if b, ok := tmpl.(blockTemplate); ok {
+		t, err := r.withFuncs(key, b.Template, funcs)
+		if err != nil {
+			return nil, err
+		}
+		return blockTemplate{t, b.block}, nil
+	}
+	if t, ok := tmpl.(*texttemplate.Template); ok {
+		clone, err := t.Clone()
+		if err != nil {
+			return nil, err
+		}
+		return clone.Funcs(funcs), nil
+	}
+	if _, ok := tmpl.(*htmltemplate.Template); !ok {
+		return nil, fmt.Errorf("template %s can't take request funcs", key)
+	}
+
+	base, err := r.cloneBase(key, tmpl)
+	if err != nil {
+		return nil, err
+	}
+	clone, err := base.(*htmltemplate.Template).Clone()
+	if err != nil {
+		return nil, err
+	}
+	return clone.Funcs(funcs), nil
+}
+
+// cloneBase returns the unexecuted copy of tmpl, the template key, taking
+// it on first use.
+func (r *Reloader) cloneBase(key string, tmpl Template) (Template, error) {
+	r.RLock()
+	b, ok := r.bases[key]
+	r.RUnlock()
+	if !ok || b.of != tmpl {
+		r.Lock()
+		if b, ok = r.bases[key]; !ok || b.of != tmpl {
+			b = &cloneBase{of: tmpl}
+			if r.bases == nil {
+				r.bases = map[string]*cloneBase{}
+			}
+			r.bases[key] = b
+		}
+		r.Unlock()
+	}
+	b.once.Do(func() { b.base, b.err = r.newCloneBase(key, tmpl) })
+	return b.base, b.err
+}
+
+// newCloneBase copies tmpl, the template key, if it hasn't executed yet.
+// Otherwise a copy is parsed afresh from the file providing it. Templates
+// added with AddTemplate have none, and can't take request funcs once
+// they've executed.
+func (r *Reloader) newCloneBase(key string, tmpl Template) (Template, error) {
+	if b, ok := tmpl.(blockTemplate); ok {
+		tmpl = b.Template
+	}
+	t, ok := tmpl.(*htmltemplate.Template)
+	if !ok {
+		return nil, fmt.Errorf("template %s can't take request funcs", key)
+	}
+	base, err := t.Clone()
+	if err == nil {
+		return base, nil
+	}
+	r.RLock()
+	path, ok := r.sources[key]
+	r.RUnlock()
+	if !ok {
+		return nil, err
+	}
+	fresh, perr := r.parsePage(path)
+	if perr != nil {
+		return nil, err
+	}
+	if b, ok := fresh.(blockTemplate); ok {
+		fresh = b.Template
+	}
+	debugf("Template %s executed before its first request funcs; parsed a copy of %s.\n",
+		key, path)
+	return fresh, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// nonceFuncs stubs the request func nonce, so templates calling it parse.
+var nonceFuncs = WithFuncs(map[string]interface{}{"nonce": func() string { return "" }})
+
+// renderRequest returns the body RenderRequest responds with for key, with
+// nonce returning n.
+func renderRequest(t testing.TB, r *Reloader, key, n string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/"+key, nil)
+	funcs := map[string]interface{}{"nonce": func() string { return n }}
+	if err := r.RenderRequest(w, req, key, nil, funcs); err != nil {
+		t.Errorf("RenderRequest(%s): %v", key, err)
+	}
+	return w.Body.String()
+}
+
+func TestRenderRequestUsesStoredTemplate(t *testing.T) {
+	r, dir, _ := newTestReloader(t, map[string]string{"index.html": "{{nonce}} one"}, nonceFuncs)
+	// Until the change is handled, the template served stays the same
+	// with request funcs or without.
+	writeFiles(t, dir, map[string]string{"index.html": "{{nonce}} two"})
+	if out := renderRequest(t, r, "index", "a"); out != "a one" {
+		t.Errorf("index = %q before the change was handled, want a one", out)
+	}
+	if out := execute(t, r, "index", nil); out != " one" {
+		t.Errorf("index = %q without request funcs, want the stub's", out)
+	}
+}
+
+func TestRenderRequestDuringReloads(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "{{nonce}} 0"}, nonceFuncs)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n := fmt.Sprint("req", i)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if out := renderRequest(t, r, "index", n); !strings.HasPrefix(out, n+" ") {
+					t.Errorf("index = %q, want it to start with %s", out, n)
+					return
+				}
+			}
+		}(i)
+	}
+	for v := 1; v <= 5; v++ {
+		edit(t, dir, watchers, map[string]string{"index.html": fmt.Sprintf("{{nonce}} %d", v)})
+	}
+	close(done)
+	wg.Wait()
+	if out := renderRequest(t, r, "index", "last"); out != "last 5" {
+		t.Errorf("index = %q, want last 5", out)
+	}
+}
+
+func TestCloneBaseTakenOnFirstRequest(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "{{nonce}} one"}, nonceFuncs)
+	edit(t, dir, watchers, map[string]string{"index.html": "{{nonce}} two"})
+	taken := func() bool {
+		r.RLock()
+		defer r.RUnlock()
+		_, ok := r.bases["index"]
+		return ok
+	}
+	if taken() {
+		t.Error("copy for request funcs taken when the template was stored")
+	}
+	// Once the template has executed it can't be cloned, so the copy is
+	// parsed from its file.
+	if out := execute(t, r, "index", nil); out != " two" {
+		t.Errorf("index = %q without request funcs, want the stub's", out)
+	}
+	if taken() {
+		t.Error("copy for request funcs taken without any")
+	}
+	for _, n := range []string{"a", "b"} {
+		if out := renderRequest(t, r, "index", n); out != n+" two" {
+			t.Errorf("index = %q, want %s two", out, n)
+		}
+	}
+	if !taken() {
+		t.Error("copy for request funcs not kept after the first request")
+	}
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Root is a directory of templates and how to load them.
+type Root struct {
+	Path string
+	// Ext lists the extensions of template files, those given to
+	// WithExtensions or TemplateExt if empty.
+	// When set, changes to other files in the root are ignored; otherwise
+	// they reload pages without anything to parse, like stylesheets.
+	Ext []string
+	// Engine parses the templates, html/template by default.
+	Engine Engine
+	// Prefix is prepended to the keys of templates in this root, like
+	// "emails/" to keep them apart from templates of other roots. Roots
+	// sharing a prefix override each other's templates.
+	Prefix string
+	// Exclude lists patterns, as for WithExclude, leaving paths in this
+	// root alone.
+	Exclude []string
+	// MaxDepth limits how many levels of directories below Path are
+	// watched and scanned, 1 being its subdirectories. It is unlimited if
+	// zero, and RootOnly keeps to Path itself.
+	MaxDepth int
+	// Loader provides the templates of the root instead of the files
+	// below Path, which is then only passed to WithKeyFunc. Such a root
+	// isn't watched: the loader reports its own changes. Files in other
+	// roots are read through a FileLoader.
+	Loader Loader
+
+	// file is set for roots naming a single template file rather than a
+	// directory. Its key is the file name, and its directory is watched
+	// for changes to just that file.
+	file bool
+	// defaultExt are the extensions given to WithExtensions and
+	// WithEngine.
+	defaultExt []string
+}
+
+// RootOnly is the Root.MaxDepth leaving out every directory below the root.
+const RootOnly = -1
+
+// dir returns the directory to watch for the root.
+func (root Root) dir() string {
+	if root.file {
+		return filepath.Dir(root.Path)
+	}
+	return root.Path
+}
+
+// isFileRoot reports whether path names a template file rather than a
+// directory. Missing files are recognized by their extension.
+func (root Root) isFileRoot() bool {
+	info, err := os.Stat(root.Path)
+	if err != nil {
+		_, ok := root.templateExt(root.Path)
+		return ok
+	}
+	return !info.IsDir()
+}
+
+// exts returns the extensions of template files in the root.
+func (root Root) exts() []string {
+	switch {
+	case len(root.Ext) > 0:
+		return root.Ext
+	case len(root.defaultExt) > 0:
+		return root.defaultExt
+	}
+	return []string{TemplateExt}
+}
+
+// WithExtensions sets the extensions of template files in the roots not
+// listing their own, like WithExtensions(".html", ".gohtml", ".tmpl").
+// Keys leave out whichever extension matched.
+func WithExtensions(exts ...string) Option {
+	return optionFunc(func(r *Reloader) {
+		for _, ext := range exts {
+			if ext = strings.TrimSpace(ext); ext != "" {
+				if !strings.HasPrefix(ext, ".") {
+					ext = "." + ext
+				}
+				r.extensions = append(r.extensions, ext)
+			}
+		}
+	})
+}
+
+// setUp completes root with what it takes from the Reloader.
+func (r *Reloader) setUp(root Root) Root {
+	root.defaultExt = r.extensions
+	if len(r.engines) > 0 {
+		root.defaultExt = r.defaultExts()
+	}
+	root.file = root.Loader == nil && root.isFileRoot()
+	return root
+}
+
+// defaultExts returns the extensions of template files in the roots not
+// listing their own: those given to WithExtensions and WithEngine.
+func (r *Reloader) defaultExts() []string {
+	exts := slices.Clone(r.extensions)
+	if len(exts) == 0 {
+		exts = []string{TemplateExt}
+	}
+	for _, ext := range r.engineExts() {
+		if !slices.Contains(exts, ext) {
+			exts = append(exts, ext)
+		}
+	}
+	return exts
+}
+
+// templateExt returns the extension of name if it is a template in root.
+func (root Root) templateExt(name string) (string, bool) {
+	for _, ext := range root.exts() {
+		if strings.HasSuffix(name, ext) {
+			return ext, true
+		}
+	}
+	return "", false
+}
+
+// tooDeep reports whether dir lies further below its root than the root's
+// MaxDepth allows.
+func (r *Reloader) tooDeep(dir string) bool {
+	root, _, ok := r.fileRoot(dir)
+	if !ok || root.MaxDepth == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(root.Path, dir)
+	if err != nil || rel == "." {
+		return false
+	}
+	depth := len(strings.Split(rel, string(filepath.Separator)))
+	return depth > max(root.MaxDepth, 0)
+}
+
+// isForeign reports whether name is in a root listing its own extensions
+// without being one of them.
+func (r *Reloader) isForeign(name string) bool {
+	root, _, ok := r.fileRoot(name)
+	if !ok || len(root.Ext) == 0 {
+		return false
+	}
+	_, ok = root.templateExt(name)
+	return !ok
+}
+
+// rootList returns the roots. The slice is replaced rather than modified
+// when roots are added or removed, so it can be used without locking.
+func (r *Reloader) rootList() []Root {
+	r.rootsMu.RLock()
+	defer r.rootsMu.RUnlock()
+	return r.roots
+}
+
+// rootDirs returns the directories to watch for all roots.
+func (r *Reloader) rootDirs() []string {
+	roots := r.rootList()
+	dirs := make([]string, len(roots))
+	for i, root := range roots {
+		dirs[i] = root.dir()
+	}
+	return dirs
+}
+
+// isRootSibling reports whether name is only watched because it shares a
+// directory with a file root.
+func (r *Reloader) isRootSibling(name string) bool {
+	if _, _, ok := r.fileRoot(name); ok {
+		return false
+	}
+	for _, root := range r.rootList() {
+		if root.file && filepath.Dir(filepath.Clean(name)) == filepath.Clean(root.dir()) {
+			return true
+		}
+	}
+	return false
+}
+
+// rootPaths returns the directories and files of all roots.
+func (r *Reloader) rootPaths() []string {
+	roots := r.rootList()
+	paths := make([]string, len(roots))
+	for i, root := range roots {
+		paths[i] = root.Path
+	}
+	return paths
+}
+
+// fileRoot returns the root name belongs to and its index, later roots
+// taking precedence over earlier ones. When roots are nested, the most
+// specific one wins.
+func (r *Reloader) fileRoot(name string) (Root, int, bool) {
+	roots := r.rootList()
+	best := -1
+	for i, root := range roots {
+		if !isWithin(name, root.Path) {
+			continue
+		}
+		if best < 0 || len(filepath.Clean(root.Path)) >
+			len(filepath.Clean(roots[best].Path)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return Root{}, best, false
+	}
+	return roots[best], best, true
+}
+
+// templateKey returns the key the template file name is stored under, and
+// false if name is not a template. The key is the root's prefix followed by
+// the path relative to the root, slash separated and without extension, so
+// both "theme/admin/users.html" and "site/admin/users.html" are
+// "admin/users". Backslashes are separators too, so "site\admin\users.html"
+// reported on Windows has the same key.
+func (r *Reloader) templateKey(name string) (string, bool) {
+	root, _, ok := r.fileRoot(name)
+	if !ok {
+		return "", false
+	}
+	rel, err := filepath.Rel(root.dir(), name)
+	if err != nil {
+		return "", false
+	}
+	return r.rootKey(root, rel)
+}
+
+// rootKey returns the key of the template at the path rel relative to
+// root, or within the loader of root, and false if rel is not a template.
+func (r *Reloader) rootKey(root Root, rel string) (string, bool) {
+	ext, ok := root.templateExt(rel)
+	if !ok {
+		return "", false
+	}
+	if r.keyFunc != nil {
+		key := r.keyFunc(root.Path, slashed(rel))
+		return root.Prefix + key, key != ""
+	}
+	return root.Prefix + slashed(strings.TrimSuffix(rel, ext)), true
+}
+
+// WithKeyFunc derives template keys with key instead of the default scheme
+// described on Reloader. It's called with the root's Path and the slash
+// separated path of the file relative to it, like "admin/Users.html", and
+// returns the key, to which the root's Prefix is still prepended, or "" if
+// the file is not a template. Keys may be anything, like the base name,
+// as long as key always returns the same one for the same file.
+func WithKeyFunc(key func(root, path string) string) Option {
+	return optionFunc(func(r *Reloader) { r.keyFunc = key })
+}
+
+// resolve returns the file currently providing key. Roots passed later to
+// New override earlier ones, so the last root having the file wins, unless
+// RefuseCollisions says otherwise. Excluded files and those below a root's
+// MaxDepth provide nothing.
+func (r *Reloader) resolve(key string) (string, bool) {
+	if r.keyFunc != nil {
+		return r.resolveAny(key)
+	}
+	roots := r.rootList()
+	for _, i := range r.rootOrder(roots) {
+		root := roots[i]
+		if !strings.HasPrefix(key, root.Prefix) {
+			continue
+		}
+		if root.file {
+			if k, ok := r.templateKey(root.Path); ok && k == key {
+				if _, err := os.Stat(root.Path); err == nil {
+					return root.Path, true
+				}
+			}
+			continue
+		}
+		base := filepath.Join(root.Path,
+			filepath.FromSlash(strings.TrimPrefix(key, root.Prefix)))
+
+		for _, ext := range root.exts() {
+			path := base + ext
+			if r.isExcluded(path) || r.tooDeep(filepath.Dir(path)) {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			// With nested roots the file may belong to a more specific
+			// root, under a different key.
+			if k, _ := r.templateKey(path); k == key {
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
+
+// resolveAny is resolve for keys that don't tell the file's path, looking
+// through every file in the roots.
+func (r *Reloader) resolveAny(key string) (string, bool) {
+	roots := r.rootList()
+	for _, i := range r.rootOrder(roots) {
+		paths := slices.Clone(r.keyPaths(roots[i].Path)[key])
+		if len(paths) > 0 {
+			sort.Slice(paths, func(a, b int) bool { return r.prefers(paths[a], paths[b]) })
+			return paths[0], true
+		}
+	}
+	return "", false
+}
+
+// maxMissing bounds how many keys without a file are remembered, so
+// requests for made up paths can't grow the index without limit.
+const maxMissing = 1024
+
+// keyIndex holds the files providing each key in every root as of the
+// last change, so resolveAny doesn't walk the roots for every lookup, and
+// the keys Get found no file for.
+type keyIndex struct {
+	mu sync.Mutex
+	// gen counts invalidations, so what was looked up meanwhile isn't
+	// kept.
+	gen     uint64
+	roots   map[string]map[string][]string
+	missing map[string]bool
+}
+
+// keyPaths returns the files providing each key in the root dir.
+func (r *Reloader) keyPaths(dir string) map[string][]string {
+	ix := &r.keyIndex
+	ix.mu.Lock()
+	paths, ok := ix.roots[dir]
+	gen := ix.gen
+	ix.mu.Unlock()
+	if ok {
+		return paths
+	}
+
+	paths = map[string][]string{}
+	_, dirs := walk([]string{dir}, r.walkOptions())
+	for _, list := range dirs {
+		for _, f := range list {
+			if key, ok := r.templateKey(f.path); ok {
+				paths[key] = append(paths[key], f.path)
+			}
+		}
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.gen == gen {
+		if ix.roots == nil {
+			ix.roots = map[string]map[string][]string{}
+		}
+		ix.roots[dir] = paths
+	}
+	return paths
+}
+
+// invalidateKeys drops the files indexed by keyPaths and the keys found
+// missing, once files may have been added or removed.
+func (r *Reloader) invalidateKeys() {
+	ix := &r.keyIndex
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.gen++
+	ix.roots = nil
+	ix.missing = nil
+}
+
+// keysGen returns how many times the index was invalidated, to tell
+// whether files may have changed since.
+func (r *Reloader) keysGen() uint64 {
+	ix := &r.keyIndex
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.gen
+}
+
+// isMissing reports whether no file provided key when last looked for.
+func (r *Reloader) isMissing(key string) bool {
+	ix := &r.keyIndex
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.missing[key]
+}
+
+// setMissing records that no file provides key, as found when the index
+// was at gen.
+func (r *Reloader) setMissing(key string, gen uint64) {
+	ix := &r.keyIndex
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.gen != gen || len(ix.missing) >= maxMissing {
+		return
+	}
+	if ix.missing == nil {
+		ix.missing = map[string]bool{}
+	}
+	ix.missing[key] = true
+}
+
+// engine returns the engine parsing the template file name, the one given
+// to WithEngine for the longest extension it has or else its root's.
+func (r *Reloader) engine(name string) Engine {
+	root, _, _ := r.fileRoot(name)
+	return r.rootEngine(root, name)
+}
+
+// rootEngine returns the engine parsing the template name in root.
+func (r *Reloader) rootEngine(root Root, name string) Engine {
+	match := ""
+	for ext := range r.engines {
+		if strings.HasSuffix(name, ext) && len(ext) > len(match) {
+			match = ext
+		}
+	}
+	if match != "" {
+		return r.engines[match]
+	}
+	return root.Engine
+}
+
+// Sources returns the file providing each template and partial key.
+func (r *Reloader) Sources() map[string]string {
+	r.RLock()
+	defer r.RUnlock()
+	sources := make(map[string]string, len(r.sources)+len(r.partials))
+	for key, path := range r.sources {
+		sources[key] = path
+	}
+	for key, path := range r.partials {
+		sources[key] = path
+	}
+	return sources
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestPerRootSettings(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"templates/index.html": "index",
+		"emails/welcome.txt":   "welcome",
+		"emails/preview.html":  "preview",
+	})
+	emails := filepath.Join(dir, "emails")
+	r, watchers := startTestReloader(t,
+		Root{Path: filepath.Join(dir, "templates"), Ext: []string{".html"}},
+		Root{Path: emails, Ext: []string{".txt"}, Prefix: "email/"})
+
+	if !r.isIgnored(filepath.Join(emails, "preview.html")) {
+		t.Error("emails/preview.html isn't ignored")
+	}
+	if _, err := r.Get("email/preview"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Get(email/preview) = %v, want ErrTemplateNotFound", err)
+	}
+	edit(t, emails, watchers, map[string]string{"welcome.txt": "welcome back"})
+	if out := execute(t, r, "email/welcome", nil); out != "welcome back" {
+		t.Errorf("email/welcome = %q, want welcome back", out)
+	}
+	if v, _ := r.Version("email/welcome"); v != 2 {
+		t.Errorf("Version(email/welcome) = %d, want 2", v)
+	}
+
+	since := currentVersion()
+	writeFiles(t, dir, map[string]string{"outside.html": "outside"})
+	watchers.last().Send(filepath.Join(dir, "outside.html"), fsnotify.Create)
+	time.Sleep(20 * testDelay)
+	if v := currentVersion(); v != since {
+		t.Errorf("a file outside of every root published %d messages", v.Since(since))
+	}
+}
+
+// TestConcurrentIsExcluded checks, under -race, that matching doesn't
+// write into the spare capacity of a root's Exclude.
+func TestConcurrentIsExcluded(t *testing.T) {
+	exclude := make([]string, 1, 8)
+	exclude[0] = "drafts/**"
+	r, dir, _ := newTestReloader(t, nil, WithExclude("*.bak"))
+	r.roots[0].Exclude = exclude
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if r.isExcluded(filepath.Join(dir, "index.html")) {
+					t.Error("index.html is excluded")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTemplateKeyWindowsPaths(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		sep  byte
+		want string
+	}{
+		{`C:\site\admin\users.html`, '\\', "C:/site/admin/users.html"},
+		{`admin\users.html`, '\\', "admin/users.html"},
+		{"admin/users.html", '\\', "admin/users.html"},
+		{`admin\users.html`, '/', `admin\users.html`},
+	} {
+		if got := toSlash(tt.name, tt.sep); got != tt.want {
+			t.Errorf("toSlash(%q, %q) = %q, want %q", tt.name, tt.sep, got, tt.want)
+		}
+	}
+
+	r, dir, _ := newTestReloader(t, nil, Root{Path: t.TempDir(), Prefix: "site/"})
+	site := r.rootList()[1].Path
+	tests := []struct{ name, want string }{
+		{filepath.Join(dir, "admin", "users.html"), "admin/users"},
+		{dir + "/./admin/../index.html", "index"},
+	}
+	if filepath.Separator == '\\' {
+		// Below the root, a name with backslashes is as Windows reports it.
+		tests = append(tests,
+			struct{ name, want string }{filepath.Join(dir, `admin\users.html`), "admin/users"},
+			struct{ name, want string }{filepath.Join(site, `emails\welcome.html`), "site/emails/welcome"})
+	}
+	for _, tt := range tests {
+		if got, ok := r.templateKey(tt.name); !ok || got != tt.want {
+			t.Errorf("templateKey(%q) = %q, %v; want %q", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestBackslashInFileName(t *testing.T) {
+	if filepath.Separator == '\\' {
+		t.Skip("backslashes separate paths on Windows")
+	}
+	r, dir, _ := newTestReloader(t, map[string]string{`a\b.html`: "backslash"},
+		WithExclude(`drafts\*.html`))
+	if key, ok := r.templateKey(filepath.Join(dir, `a\b.html`)); !ok || key != `a\b` {
+		t.Errorf("templateKey(a\\b.html) = %q, %v; want a\\b", key, ok)
+	}
+	if out := execute(t, r, `a\b`, nil); out != "backslash" {
+		t.Errorf(`a\b = %q, want backslash`, out)
+	}
+	// Patterns are still written with either separator.
+	if !r.isExcluded(filepath.Join(dir, "drafts", "post.html")) {
+		t.Error(`drafts/post.html isn't excluded by drafts\*.html`)
+	}
+}
+
+func TestExtensions(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"index.html":   "index",
+		"about.gohtml": "about",
+		"list.tmpl":    "list",
+		"style.css":    "body {}",
+	}, WithExtensions(".html", ".gohtml", "tmpl"))
+	if want := []string{"about", "index", "list"}; !slices.Equal(r.Names(), want) {
+		t.Errorf("Names() = %v, want %v", r.Names(), want)
+	}
+
+	edit(t, dir, watchers, map[string]string{"about.gohtml": "about us"})
+	if out := execute(t, r, "about", nil); out != "about us" {
+		t.Errorf("about = %q, want about us", out)
+	}
+	errs := r.ErrorLog()
+	if msg := edit(t, dir, watchers, map[string]string{"style.css": "body { margin: 0 }"}); msg.Type != MessageReload {
+		t.Errorf("got %s message for a stylesheet, want %s", msg.Type, MessageReload)
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("editing a stylesheet reported %v", err)
+	default:
+	}
+	if _, err := r.Get("style"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Get(style) = %v, want ErrTemplateNotFound", err)
+	}
+}
+
+// lowerKey is a KeyFunc lowercasing keys and leaving out "pages/".
+func lowerKey(root, path string) string {
+	path = strings.TrimSuffix(path, filepath.Ext(path))
+	return strings.ToLower(strings.TrimPrefix(path, "pages/"))
+}
+
+func TestKeyFunc(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"pages/Admin/Users.html": "users",
+	}, WithKeyFunc(lowerKey))
+	if out := execute(t, r, "admin/users", nil); out != "users" {
+		t.Errorf("admin/users = %q, want users", out)
+	}
+	edit(t, dir, watchers, map[string]string{"pages/Admin/Users.html": "all users"})
+	if out := execute(t, r, "admin/users", nil); out != "all users" {
+		t.Errorf("admin/users = %q once edited, want all users", out)
+	}
+
+	path := filepath.Join(dir, "pages", "Admin", "Users.html")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	since := currentVersion()
+	watchers.last().Send(path, fsnotify.Remove)
+	published(t, since)
+	if _, err := r.Get("admin/users"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Get(admin/users) = %v once removed, want ErrTemplateNotFound", err)
+	}
+	if names := r.Names(); len(names) > 0 {
+		t.Errorf("Names() = %v once removed, want none", names)
+	}
+}
+
+func TestKeyFuncIndex(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"pages/Index.html": "index"},
+		WithKeyFunc(lowerKey))
+	if _, ok := r.resolve("index"); !ok {
+		t.Fatal("index isn't resolved")
+	}
+	r.keyIndex.mu.Lock()
+	if len(r.keyIndex.roots) != 1 {
+		t.Error("the files of the root aren't indexed")
+	}
+	r.keyIndex.mu.Unlock()
+
+	// Until a change is reported, resolving uses the index.
+	writeFiles(t, dir, map[string]string{"pages/About.html": "about"})
+	if path, ok := r.resolve("about"); ok {
+		t.Errorf("resolved about to %s without walking the root again", path)
+	}
+	since := currentVersion()
+	watchers.last().Send(filepath.Join(dir, "pages", "About.html"), fsnotify.Create)
+	published(t, since)
+	if out := execute(t, r, "about", nil); out != "about" {
+		t.Errorf("about = %q, want about", out)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Editors often save by writing a temporary file and renaming it over the
+// original, so a file can briefly be missing, or renamed away, before its
+// replacement appears. Retries wait for it starting at saveRetryDelay and
+// doubling up to maxSaveRetryDelay.
+const (
+	saveRetryDelay    = 10 * time.Millisecond
+	maxSaveRetryDelay = 80 * time.Millisecond
+)
+
+// DefaultSettle is how long a changed file's size and modification time
+// have to stay the same before it's read, unless Reloader.Settle says
+// otherwise. Debouncing collapses events, but a build step writing a large
+// template in chunks can pause longer than that between them.
+const DefaultSettle = 50 * time.Millisecond
+
+// maxSettleWait bounds how long awaitStable waits for files that keep
+// changing.
+const maxSettleWait = 5 * time.Second
+
+// awaitStable waits for the files changed by evts to stop being written,
+// so none is parsed while still incomplete. All of them are checked
+// together, so a batch waits about as long as its slowest file. Files not
+// modified for a whole interval already are taken as stable right away.
+func (r *Reloader) awaitStable(evts []ChangeEvent) {
+	interval := r.Settle
+	if interval <= 0 {
+		interval = DefaultSettle
+	}
+
+	last := map[string]os.FileInfo{}
+	for _, evt := range evts {
+		info, err := os.Stat(evt.Path)
+		if err != nil || info.IsDir() || time.Since(info.ModTime()) >= interval {
+			continue
+		}
+		last[evt.Path] = info
+	}
+	deadline := time.Now().Add(maxSettleWait)
+	for len(last) > 0 && time.Now().Before(deadline) {
+		time.Sleep(interval)
+		for path, before := range last {
+			info, err := os.Stat(path)
+			if err != nil || info.Size() == before.Size() &&
+				info.ModTime().Equal(before.ModTime()) {
+				delete(last, path)
+				continue
+			}
+			debugf("File: %s is still being written.\n", path)
+			last[path] = info
+		}
+	}
+}
+
+// awaitReplacement waits a little for a removed or renamed template to be
+// replaced, so an atomic save doesn't evict it in between.
+func awaitReplacement(path string) {
+	for delay := saveRetryDelay; delay <= maxSaveRetryDelay; delay *= 2 {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		time.Sleep(delay)
+	}
+}
+
+// parseSaved parses the page at path, retrying while the file or a partial
+// is missing mid-save.
+func (r *Reloader) parseSaved(path string) (Template, error) {
+	delay := saveRetryDelay
+	for {
+		tmpl, err := r.parsePage(path)
+		if !errors.Is(err, fs.ErrNotExist) || delay > maxSaveRetryDelay {
+			return tmpl, err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
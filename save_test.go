@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestAtomicSavesKeepReloading(t *testing.T) {
+	s := NewTestServer(t)
+	s.WriteTemplate("index.html", "<p>save 0</p>")
+	s.ExpectReload(testTimeout)
+
+	// Like vim and GoLand: write a temporary file, then rename it over
+	// the template.
+	path := filepath.Join(s.Dir, "index.html")
+	for i := 1; i <= 10; i++ {
+		want := fmt.Sprintf("<p>save %d</p>", i)
+		s.WriteTemplate("index.html.tmp", want)
+		if err := os.Rename(path+".tmp", path); err != nil {
+			t.Fatal(err)
+		}
+		s.ExpectReload(testTimeout)
+		if body := s.get("/"); !strings.Contains(body, want) {
+			t.Fatalf("save %d: page = %q, want it to contain %q", i, body, want)
+		}
+	}
+}
+
+func TestRenameBeforeReplacementKeepsTemplate(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "one"})
+	path := filepath.Join(dir, "index.html")
+	if err := os.Rename(path, path+"~"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rename is reported before the new file is in place.
+	since := currentVersion()
+	watchers.last().Send(path, fsnotify.Rename)
+	writeFiles(t, dir, map[string]string{"index.html": "two"})
+	published(t, since)
+	if out := execute(t, r, "index", nil); out != "two" {
+		t.Errorf("index = %q, want two", out)
+	}
+}
+
+func TestSettleWaitsForChunkedWrite(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "one"})
+	r.Settle = 50 * time.Millisecond
+	path := filepath.Join(dir, "index.html")
+
+	// The first chunk alone doesn't parse.
+	writeFiles(t, dir, map[string]string{"index.html": "{{if .Ready}}ready"})
+	since := currentVersion()
+	watchers.last().Send(path, fsnotify.Write)
+	time.Sleep(20 * time.Millisecond)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{{end}}")
+	f.Close()
+	watchers.last().Send(path, fsnotify.Write)
+
+	if msg := published(t, since); msg.Type != MessageReload {
+		t.Fatalf("got %s message %q, want %s", msg.Type, msg.Error, MessageReload)
+	}
+	time.Sleep(3 * r.Settle)
+	if v, _ := r.Version("index"); v != 2 {
+		t.Errorf("Version(index) = %d, want 2 from a single parse", v)
+	}
+	if errs := r.ParseErrors(); len(errs) > 0 {
+		t.Errorf("ParseErrors() = %v", errs)
+	}
+	if out := execute(t, r, "index", map[string]bool{"Ready": true}); out != "ready" {
+		t.Errorf("index = %q, want ready", out)
+	}
+}
+
+func TestSettleSkipsStableFiles(t *testing.T) {
+	r, dir, _ := newTestReloader(t, map[string]string{"index.html": "one"})
+	r.Settle = time.Second
+	path := filepath.Join(dir, "index.html")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	r.awaitStable([]ChangeEvent{{path, fsnotify.Write}})
+	if waited := time.Since(start); waited >= r.Settle {
+		t.Errorf("waited %v for a file last written an hour ago", waited)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// fingerprint summarizes the files directly inside a directory. Rescans
+// compare fingerprints to skip directories where nothing changed.
+type fingerprint struct {
+	files int
+	// sum combines a hash of the path, modification time and size of
+	// every file, so a file moved in with an old modification time
+	// changes it too.
+	sum uint64
+}
+
+func (f fingerprint) equal(o fingerprint) bool {
+	return f.files == o.files && f.sum == o.sum
+}
+
+type scannedFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+func (f scannedFile) hash() uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%d\x00%d", f.path, f.modTime.UnixNano(), f.size)
+	return h.Sum64()
+}
+
+// same reports whether f and o are the same file, unchanged.
+func (f scannedFile) same(o scannedFile) bool {
+	return f.path == o.path && f.modTime.Equal(o.modTime) && f.size == o.size
+}
+
+// walk fingerprints every directory below dirs and lists the files in them,
+// grouped by directory.
+func walk(dirs []string, opts walkOptions) (map[string]fingerprint, map[string][]scannedFile) {
+	prints := map[string]fingerprint{}
+	files := map[string][]scannedFile{}
+	for _, root := range dirs {
+		opts.walkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				fmt.Println(err)
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+
+			dir := filepath.Dir(path)
+			f := scannedFile{path, info.ModTime(), info.Size()}
+			fp := prints[dir]
+			fp.files++
+			fp.sum ^= f.hash()
+			prints[dir] = fp
+			files[dir] = append(files[dir], f)
+			return nil
+		})
+	}
+	return prints, files
+}
+
+// Scan walks the roots and parses every template in them, replacing the
+// templates loaded before. Parsing is spread over a pool of workers so
+// large trees load quickly. Files that fail to parse are reported and
+// skipped.
+func (r *Reloader) Scan() {
+	r.scan()
+}
+
+// Reparse rereads every template, for when changes may have been missed,
+// and tells the clients to reload. Changes being handled are waited for,
+// so the two don't overwrite each other's results.
+func (r *Reloader) Reparse() {
+	parsed, errs := r.refresh()
+	fmt.Printf("Reparsed %d templates in %v, %d errors.\n",
+		parsed, r.rootPaths(), len(errs))
+}
+
+// Refresh is Reparse for applications, like after copying new templates
+// into place or changing the functions. The templates that parsed replace
+// the previous ones all at once, those whose files are gone are dropped,
+// and the errors of the files that failed are returned joined together.
+func (r *Reloader) Refresh() error {
+	_, errs := r.refresh()
+	return errors.Join(errs...)
+}
+
+// refresh rescans the roots while no change is handled and tells the
+// clients to reload once.
+func (r *Reloader) refresh() (int, []error) {
+	r.changes.handling.Lock()
+	defer r.changes.handling.Unlock()
+	parsed, errs := r.scan()
+	publish(Message{Type: MessageReload})
+	return parsed, errs
+}
+
+// Preload parses every template in the roots like Scan, returning the
+// errors of the files that failed to parse joined together. The others are
+// loaded regardless.
+func (r *Reloader) Preload() error {
+	_, errs := r.scan()
+	return errors.Join(errs...)
+}
+
+// scan is Scan, returning how many templates were parsed and the errors of
+// those that failed to parse.
+func (r *Reloader) scan() (int, []error) {
+	r.invalidateKeys()
+	prints, files := walk(append(r.rootPaths(), r.static...), r.walkOptions())
+
+	// Find the file providing each key, see WithCollisions. Pages are
+	// parsed together with the partials, so those have to be known before
+	// parsing starts.
+	found := map[string]string{}
+	for dir, list := range files {
+		if r.isStatic(dir) {
+			continue
+		}
+		for _, f := range list {
+			key, ok := r.templateKey(f.path)
+			if !ok {
+				continue
+			}
+			path, seen := found[key]
+			if !seen {
+				found[key] = f.path
+				continue
+			}
+			if r.prefers(f.path, path) {
+				found[key] = f.path
+				r.collided(key, f.path, path)
+			} else {
+				r.collided(key, path, f.path)
+			}
+		}
+	}
+
+	pages := map[string]string{}
+	partials := map[string]string{}
+	for key, path := range found {
+		if r.isPartial(key) {
+			partials[key] = path
+			r.changes.remember(path)
+		} else {
+			pages[key] = path
+		}
+	}
+	r.Lock()
+	r.partials = partials
+	r.Unlock()
+
+	type page struct {
+		path    string
+		tmpl    Template
+		outline setOutline
+	}
+	parsed := map[string]page{}
+	failed := map[string]error{}
+	var errs []error
+	r.parseEach(pages, func(key, path string, tmpl Template, err error) {
+		if err != nil {
+			err = &ChangeError{Path: path, Err: err}
+			r.errors.print(err)
+			errs = append(errs, err)
+			failed[key] = err
+			return
+		}
+		parsed[key] = page{path, tmpl, outline(tmpl)}
+	})
+
+	// The templates are replaced all at once, dropping those whose files
+	// are gone. Pages failing to parse keep their previous template.
+	now := time.Now()
+	r.Lock()
+	templates := map[string]Template{}
+	sources := map[string]string{}
+	loaded := map[string]time.Time{}
+	versions := map[string]uint64{}
+	parseErrors := map[string]error{}
+	outlines := map[string]setOutline{}
+	for key, err := range failed {
+		if tmpl, ok := r.templates.get(key); ok {
+			templates[key] = tmpl
+			outlines[key] = r.outlines[key]
+			sources[key] = r.sources[key]
+			loaded[key] = r.loaded[key]
+			versions[key] = r.versions[key]
+			parseErrors[key] = err
+		}
+	}
+	for key, p := range parsed {
+		templates[key] = p.tmpl
+		outlines[key] = p.outline
+		sources[key] = p.path
+		loaded[key] = now
+		versions[key] = r.versions[key] + 1
+	}
+	r.templates.replace(templates)
+	r.sources, r.loaded, r.versions = sources, loaded, versions
+	r.parseErrors = parseErrors
+	r.outlines = outlines
+	r.fingerprints, r.scanned = prints, files
+	r.deps = nil
+	r.Unlock()
+	for key := range templates {
+		r.track(key)
+	}
+	for _, g := range r.globSets {
+		if err := r.parseGlobSet(g); err != nil {
+			r.errors.print(err)
+			errs = append(errs, err)
+		}
+	}
+	for _, err := range r.loadLoaders() {
+		r.errors.print(err)
+		errs = append(errs, err)
+	}
+	for _, err := range r.loadLocales() {
+		r.errors.print(err)
+		errs = append(errs, err)
+	}
+	return len(parsed) + len(partials), errs
+}
+
+// rescan reloads the files that changed since the last scan, looking only
+// into directories whose fingerprint changed. Files that appeared, changed
+// or vanished there are told apart by comparing the files listed then and
+// now, and the vanished ones are evicted. Clients are told to reload if
+// anything changed.
+func (r *Reloader) rescan() {
+	r.invalidateKeys()
+	prints, files := walk(append(r.rootPaths(), r.static...), r.walkOptions())
+
+	r.Lock()
+	oldPrints, oldFiles := r.fingerprints, r.scanned
+	r.fingerprints, r.scanned = prints, files
+	r.Unlock()
+
+	dirs := map[string]bool{}
+	for dir := range oldPrints {
+		dirs[dir] = true
+	}
+	for dir := range prints {
+		dirs[dir] = true
+	}
+	changed := false
+	for dir := range dirs {
+		before, seen := oldPrints[dir]
+		if fp, ok := prints[dir]; ok && seen && fp.equal(before) {
+			continue
+		}
+		changed = true
+		if r.isStatic(dir) {
+			for _, f := range append(oldFiles[dir], files[dir]...) {
+				r.invalidateAsset(f.path)
+			}
+			continue
+		}
+
+		gone := map[string]bool{}
+		known := map[string]scannedFile{}
+		for _, f := range oldFiles[dir] {
+			gone[f.path] = true
+			known[f.path] = f
+		}
+		var paths []string
+		for _, f := range files[dir] {
+			delete(gone, f.path)
+			if old, ok := known[f.path]; ok && old.same(f) {
+				continue
+			}
+			fmt.Printf("File: %s changed while unwatched. Hot reloading.\n",
+				f.path)
+			paths = append(paths, f.path)
+		}
+		for path := range gone {
+			fmt.Printf("File: %s removed while unwatched. Hot reloading.\n",
+				path)
+			paths = append(paths, path)
+		}
+		for _, path := range paths {
+			if err := r.reload(path); err != nil {
+				r.errors.print(&ChangeError{Path: path, Err: err})
+			}
+		}
+	}
+
+	if changed {
+		publish(Message{Type: MessageReload})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+// do returns the response to a method request of path, with its body.
+func (s *TestServer) do(method, path string, header http.Header) (*http.Response, string) {
+	s.t.Helper()
+	req, err := http.NewRequest(method, s.Server.URL+path, nil)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestPageMethods(t *testing.T) {
+	pages := map[string]string{
+		"injected":        "<html><body><p>index</p></body></html>",
+		"livereload func": "<html><body><p>index</p>{{livereload}}</body></html>",
+	}
+	for name, page := range pages {
+		s := NewTestServer(t)
+		s.WriteTemplate("index.html", page)
+
+		get, body := s.do(http.MethodGet, "/", nil)
+		if get.StatusCode != http.StatusOK || body == "" {
+			t.Fatalf("%s: GET got %d %q", name, get.StatusCode, body)
+		}
+		head, body := s.do(http.MethodHead, "/", nil)
+		if head.StatusCode != http.StatusOK || body != "" {
+			t.Errorf("%s: HEAD got %d %q, want 200 without a body", name, head.StatusCode, body)
+		}
+		for _, header := range []string{"Content-Type", "Content-Length"} {
+			if g, h := get.Header.Get(header), head.Header.Get(header); g != h {
+				t.Errorf("%s: %s is %q for HEAD, but %q for GET", name, header, h, g)
+			}
+		}
+
+		post, _ := s.do(http.MethodPost, "/", nil)
+		if post.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("%s: POST got %d, want %d", name, post.StatusCode, http.StatusMethodNotAllowed)
+		}
+		if allow := post.Header.Get("Allow"); allow != "GET, HEAD" {
+			t.Errorf("%s: Allow = %q, want GET, HEAD", name, allow)
+		}
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	setFlag(t, production, true)
+	s := NewTestServer(t)
+	s.WriteTemplate("index.html", "<p>index</p>")
+
+	resp, _ := s.do(http.MethodGet, "/", nil)
+	modified := resp.Header.Get("Last-Modified")
+	if modified == "" {
+		t.Fatal("no Last-Modified with -prod")
+	}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		resp, body := s.do(method, "/", http.Header{"If-Modified-Since": {modified}})
+		if resp.StatusCode != http.StatusNotModified || body != "" {
+			t.Errorf("%s got %d %q, want 304 for an unchanged page", method, resp.StatusCode, body)
+		}
+	}
+
+	// Development pages are rendered afresh every time.
+	*production = false
+	resp, _ = s.do(http.MethodGet, "/", http.Header{"If-Modified-Since": {modified}})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got %d without -prod, want 200", resp.StatusCode)
+	}
+}
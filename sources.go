@@ -0,0 +1,222 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounce is how long a file has to stay quiet before its changes
+// are handled, unless Reloader.Debounce says otherwise. A file reported
+// several times within it, by one source or by several, is handled once.
+// Later writes that leave the modification time or the contents as already
+// handled are dropped too, as when a Poller catches up with the watcher or
+// a file is touched.
+const DefaultDebounce = 100 * time.Millisecond
+
+// DefaultCoalesce is how long files that settled are collected before
+// being reloaded together, unless Reloader.Coalesce says otherwise. A burst
+// of changes to many files, like from a formatter, reloads pages once.
+const DefaultCoalesce = 200 * time.Millisecond
+
+// ChangeEvent reports that the file at Path changed.
+type ChangeEvent struct {
+	Path string
+	Op   fsnotify.Op
+}
+
+// Has reports whether the event includes op.
+func (e ChangeEvent) Has(op fsnotify.Op) bool {
+	return e.Op.Has(op)
+}
+
+// ChangeSource reports file changes from somewhere other than the
+// Reloader's own filesystem watcher, like a Poller or a Webhook.
+type ChangeSource interface {
+	Events() <-chan ChangeEvent
+	Close() error
+}
+
+// changeQueue collects changes to each file until it settles, see
+// DefaultDebounce.
+type changeQueue struct {
+	mu      sync.Mutex
+	pending map[string]*pendingChange
+	// batch holds the settled changes waiting to be handled together.
+	batch []ChangeEvent
+	timer *time.Timer
+	// handled holds the modification time of each file when its last
+	// change was handled.
+	handled map[string]time.Time
+	// hashes holds the contents of each file when its last change was
+	// handled, or when it was scanned.
+	hashes map[string][sha256.Size]byte
+	// handling serializes changes, so a slow reload isn't overtaken by
+	// the next one.
+	handling sync.Mutex
+}
+
+// pendingChange is a file's changes since it last settled.
+type pendingChange struct {
+	op    fsnotify.Op
+	timer *time.Timer
+}
+
+// AddSource feeds the changes src reports into the same reload pipeline as
+// the filesystem watcher, until src closes its Events channel. src is
+// closed once the Reloader stops, see Watch.
+func (r *Reloader) AddSource(src ChangeSource) {
+	go func() {
+		for {
+			select {
+			case evt, ok := <-src.Events():
+				if !ok {
+					return
+				}
+				r.change(evt)
+			case <-r.stopped():
+				src.Close()
+				return
+			}
+		}
+	}()
+}
+
+// stop stops handling changes and closes the sources.
+func (r *Reloader) stop() {
+	r.stopOnce.Do(func() { close(r.done) })
+}
+
+// stopped returns a channel closed once the Reloader stops, when the
+// context given to Watch is done or it's closed.
+func (r *Reloader) stopped() <-chan struct{} {
+	return r.done
+}
+
+// isStopped reports whether the Reloader stopped.
+func (r *Reloader) isStopped() bool {
+	select {
+	case <-r.stopped():
+		return true
+	default:
+		return false
+	}
+}
+
+// change queues evt to be handled once its file has settled.
+func (r *Reloader) change(evt ChangeEvent) {
+	if r.isStopped() {
+		return
+	}
+	path := filepath.Clean(evt.Path)
+	r.invalidateKeys()
+	delay := r.Debounce
+	if delay <= 0 {
+		delay = DefaultDebounce
+	}
+
+	q := &r.changes
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil {
+		q.pending = map[string]*pendingChange{}
+	}
+	if p, ok := q.pending[path]; ok {
+		p.op |= evt.Op
+		p.timer.Reset(delay)
+		return
+	}
+	q.pending[path] = &pendingChange{
+		op:    evt.Op,
+		timer: time.AfterFunc(delay, func() { r.settle(path) }),
+	}
+}
+
+// settle moves the changes queued for path into the batch.
+func (r *Reloader) settle(path string) {
+	window := r.Coalesce
+	if window <= 0 {
+		window = DefaultCoalesce
+	}
+
+	q := &r.changes
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	p, ok := q.pending[path]
+	if !ok {
+		return
+	}
+	delete(q.pending, path)
+	q.batch = append(q.batch, ChangeEvent{path, p.op})
+	if q.timer == nil {
+		q.timer = time.AfterFunc(window, r.flushChanges)
+	}
+}
+
+// flushChanges handles the batch of settled changes.
+func (r *Reloader) flushChanges() {
+	q := &r.changes
+	q.mu.Lock()
+	batch := q.batch
+	q.batch = nil
+	q.timer = nil
+	q.mu.Unlock()
+
+	sort.Slice(batch, func(i, j int) bool { return batch[i].Path < batch[j].Path })
+	// Files aren't looked at when parsing is stubbed out.
+	stubbed := r.parseHook != nil
+	if !stubbed {
+		r.awaitStable(batch)
+	}
+
+	q.handling.Lock()
+	defer q.handling.Unlock()
+	if r.isStopped() {
+		return
+	}
+	// Whatever goes wrong handling one batch, like a template function
+	// panicking in a strict dry run, the next one is handled regardless.
+	defer func() {
+		if err := recover(); err != nil {
+			r.errors.print(fmt.Errorf("Handling changes failed: %v", err))
+		}
+	}()
+	var evts []ChangeEvent
+	for _, evt := range batch {
+		if stubbed {
+			evts = append(evts, evt)
+			continue
+		}
+		if q.seen(evt.Path, evt.Op) {
+			continue
+		}
+		if q.unchanged(evt.Path, evt.Op) {
+			debugf("File: %s Event: %s. Contents unchanged.\n", evt.Path, evt.Op)
+			continue
+		}
+		evts = append(evts, evt)
+	}
+	r.handleChanges(evts)
+}
+
+// seen reports whether a write to path was already handled, recording the
+// file's modification time for next time otherwise.
+func (q *changeQueue) seen(path string, op fsnotify.Op) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		delete(q.handled, path)
+		return false
+	}
+	if q.handled == nil {
+		q.handled = map[string]time.Time{}
+	}
+	last, ok := q.handled[path]
+	q.handled[path] = info.ModTime()
+	return ok && op == fsnotify.Write && info.ModTime().Equal(last)
+}
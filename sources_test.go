@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestBurstOfWritesReloadsOnce(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "one"})
+	r.Debounce = 50 * time.Millisecond
+	path := filepath.Join(dir, "index.html")
+	writeFiles(t, dir, map[string]string{"index.html": "two"})
+
+	since := currentVersion()
+	for i := 0; i < 5; i++ {
+		watchers.last().Send(path, fsnotify.Write)
+	}
+	published(t, since)
+	time.Sleep(2 * r.Debounce)
+	if n := currentVersion().Since(since); n != 1 {
+		t.Errorf("%d messages published, want 1", n)
+	}
+	if v, _ := r.Version("index"); v != 2 {
+		t.Errorf("Version(index) = %d, want 2", v)
+	}
+}
+
+func TestDebounceIsPerFile(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"a.html": "a",
+		"b.html": "b",
+	})
+	writeFiles(t, dir, map[string]string{"a.html": "a2", "b.html": "b2"})
+	since := currentVersion()
+	watchers.last().Send(filepath.Join(dir, "a.html"), fsnotify.Write)
+	watchers.last().Send(filepath.Join(dir, "b.html"), fsnotify.Write)
+	published(t, since)
+	waitFor(t, "both templates to reload", func() bool {
+		a, _ := r.Version("a")
+		b, _ := r.Version("b")
+		return a == 2 && b == 2
+	})
+}
+
+func TestBurstOfFilesIsOneMessage(t *testing.T) {
+	watchers := &fakeWatchers{}
+	s := NewTestServer(t, watchers.option())
+	s.Coalesce = 100 * time.Millisecond
+
+	files := map[string]string{}
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("page%d.html", i)] = fmt.Sprintf("page %d", i)
+	}
+	writeFiles(t, s.Dir, files)
+	for name := range files {
+		watchers.last().Send(filepath.Join(s.Dir, name), fsnotify.Create)
+	}
+	msg := s.ExpectReload(testTimeout)
+	if len(msg.Files) != 10 {
+		t.Errorf("message lists %d files, want 10: %v", len(msg.Files), msg.Files)
+	}
+	s.ExpectNothing(2 * s.Coalesce)
+}
+
+func TestUnchangedContentsDontReload(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "one"})
+	path := filepath.Join(dir, "index.html")
+	edit(t, dir, watchers, map[string]string{"index.html": "same"})
+
+	// Rewriting the same bytes, as touch or a generator does.
+	since := currentVersion()
+	edited := time.Now().Add(time.Second)
+	writeFiles(t, dir, map[string]string{"index.html": "same"})
+	if err := os.Chtimes(path, edited, edited); err != nil {
+		t.Fatal(err)
+	}
+	watchers.last().Send(path, fsnotify.Write)
+	time.Sleep(20 * testDelay)
+	if v := currentVersion(); v != since {
+		t.Errorf("rewriting the same contents published %d messages", v.Since(since))
+	}
+	if v, _ := r.Version("index"); v != 2 {
+		t.Errorf("Version(index) = %d, want 2", v)
+	}
+
+	edit(t, dir, watchers, map[string]string{"index.html": "different"})
+	if v, _ := r.Version("index"); v != 3 {
+		t.Errorf("Version(index) = %d after a real change, want 3", v)
+	}
+}
+
+func TestRecreatedWithSameContentsReloads(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "one"})
+	path := filepath.Join(dir, "index.html")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	since := currentVersion()
+	watchers.last().Send(path, fsnotify.Remove)
+	published(t, since)
+	if _, ok := r.Version("index"); ok {
+		t.Fatal("index still has a version once removed")
+	}
+
+	since = currentVersion()
+	writeFiles(t, dir, map[string]string{"index.html": "one"})
+	watchers.last().Send(path, fsnotify.Create)
+	published(t, since)
+	if out := execute(t, r, "index", nil); out != "one" {
+		t.Errorf("index = %q, want one", out)
+	}
+}
+
+func TestPanicWhileHandlingChanges(t *testing.T) {
+	tests := map[string]Option{
+		"event filter": WithEventFilter(func(evt fsnotify.Event) bool {
+			if filepath.Base(evt.Name) == "boom.html" {
+				panic("filter failed")
+			}
+			return DefaultEventFilter(evt)
+		}),
+		"preprocessor": WithPreprocessor(func(path string, src []byte) ([]byte, error) {
+			if filepath.Base(path) == "boom.html" {
+				panic("preprocessor failed")
+			}
+			return src, nil
+		}),
+	}
+	for name, option := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "one"}, option)
+			var log lockedBuffer
+			r.errors.out = &log
+
+			writeFiles(t, dir, map[string]string{"boom.html": "boom"})
+			watchers.last().Send(filepath.Join(dir, "boom.html"), fsnotify.Create)
+			waitFor(t, "the panic to be reported", func() bool {
+				return strings.Contains(log.String(), "Handling changes failed")
+			})
+
+			// Later changes are handled all the same.
+			edit(t, dir, watchers, map[string]string{"index.html": "two"})
+			if out := execute(t, r, "index", nil); out != "two" {
+				t.Errorf("index = %q, want two", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+//go:build sprig
+
+package main
+
+import "github.com/Masterminds/sprig/v3"
+
+func init() {
+	sprigFuncs = func() map[string]interface{} { return sprig.HtmlFuncMap() }
+}
@@ -0,0 +1,38 @@
+//go:build sprig
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSprig(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"index.html": `{{"hello" | upper}}`,
+	}, WithSprig())
+	if out := execute(t, r, "index", nil); out != "HELLO" {
+		t.Errorf("index = %q once preloaded, want HELLO", out)
+	}
+	edit(t, dir, watchers, map[string]string{"index.html": `{{"hello" | upper}} {{default "none" .}}`})
+	if out := execute(t, r, "index", nil); out != "HELLO none" {
+		t.Errorf("index = %q once edited, want HELLO none", out)
+	}
+
+	// Pages loaded on demand have them too.
+	writeFiles(t, dir, map[string]string{"lazy.html": `{{"lazy" | title}}`})
+	if out := execute(t, r, "lazy", nil); out != "Lazy" {
+		t.Errorf("lazy = %q, want Lazy", out)
+	}
+}
+
+func TestFuncsOverrideSprig(t *testing.T) {
+	r, _, _ := newTestReloader(t, map[string]string{
+		"index.html": `{{"hello" | upper}} {{"x" | repeat 3}}`,
+	}, WithSprig(), WithFuncs(map[string]interface{}{
+		"upper": func(s string) string { return strings.ToUpper(s) + "!" },
+	}))
+	if out := execute(t, r, "index", nil); out != "HELLO! xxx" {
+		t.Errorf("index = %q, want HELLO! xxx", out)
+	}
+}
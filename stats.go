@@ -0,0 +1,33 @@
+package main
+
+import "sync/atomic"
+
+// Stats holds counters describing what the Reloader has been doing. It is
+// served as JSON by the stats endpoint.
+type Stats struct {
+	WatcherRestarts uint64 `json:"watcher_restarts"`
+	// WatchedDirs counts the directories being watched, and UnwatchedDirs
+	// those polled instead because the system ran out of watches.
+	WatchedDirs   int      `json:"watched_dirs"`
+	UnwatchedDirs int      `json:"unwatched_dirs"`
+	Unwatched     []string `json:"unwatched,omitempty"`
+	// ErrorsDropped counts the errors ErrorLog dropped because nobody
+	// received them.
+	ErrorsDropped uint64 `json:"errors_dropped"`
+	// SlowestTemplates are the templates that took longest to parse
+	// recently, slowest first.
+	SlowestTemplates []ParseTiming `json:"slowest_templates,omitempty"`
+}
+
+// Stats returns a snapshot of the Reloader's counters.
+func (r *Reloader) Stats() Stats {
+	watched, unwatched := r.watchCounts()
+	return Stats{
+		WatcherRestarts:  atomic.LoadUint64(&r.stats.WatcherRestarts),
+		WatchedDirs:      watched,
+		UnwatchedDirs:    unwatched,
+		Unwatched:        r.unwatchedDirs(),
+		ErrorsDropped:    atomic.LoadUint64(&r.errors.dropped),
+		SlowestTemplates: r.parseTimes.slowest(maxSlowest),
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// templateMap maps keys to templates. Reads never lock: writers, which
+// serialize among themselves, copy the map, change the copy and swap it
+// in, so a map once read is never modified. The zero value is empty and
+// ready to use.
+type templateMap struct {
+	mu sync.Mutex
+	m  atomic.Pointer[map[string]Template]
+}
+
+// load returns the current map, which must not be modified.
+func (t *templateMap) load() map[string]Template {
+	if m := t.m.Load(); m != nil {
+		return *m
+	}
+	return nil
+}
+
+// get returns the template key.
+func (t *templateMap) get(key string) (Template, bool) {
+	tmpl, ok := t.load()[key]
+	return tmpl, ok
+}
+
+// set makes tmpl the template key.
+func (t *templateMap) set(key string, tmpl Template) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m := t.clone()
+	m[key] = tmpl
+	t.m.Store(&m)
+}
+
+// delete removes the template key, reporting whether there was one.
+func (t *templateMap) delete(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.load()[key]; !ok {
+		return false
+	}
+	m := t.clone()
+	delete(m, key)
+	t.m.Store(&m)
+	return true
+}
+
+// replace replaces all templates with those in m, which must not be
+// modified afterwards.
+func (t *templateMap) replace(m map[string]Template) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.m.Store(&m)
+}
+
+// clone returns a copy of the current map to modify.
+func (t *templateMap) clone() map[string]Template {
+	old := t.load()
+	m := make(map[string]Template, len(old)+1)
+	for key, tmpl := range old {
+		m[key] = tmpl
+	}
+	return m
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	texttemplate "text/template"
+)
+
+// samples holds the data of the last successful render of each page, used
+// to dry run the page after it changes in strict mode.
+type samples struct {
+	mu   sync.Mutex
+	data map[string]interface{}
+}
+
+// isStrict reports whether strict mode is on. It never is with -prod.
+func isStrict() bool {
+	return *strict && !*production
+}
+
+// WithTemplateOptions sets options, as for template.Option, on every
+// template when it's parsed and reparsed, like "missingkey=error" to fail
+// on typos in field names of map data rather than render them empty.
+// Invalid options are reported and left out. In strict mode
+// "missingkey=error" is set regardless.
+func WithTemplateOptions(options ...string) Option {
+	return optionFunc(func(r *Reloader) {
+		for _, opt := range options {
+			if err := checkOption(opt); err != nil {
+				fmt.Println("Ignoring template option:", err)
+				continue
+			}
+			r.templateOptions = append(r.templateOptions, opt)
+		}
+	})
+}
+
+// checkOption returns an error if opt is not a valid template option.
+func checkOption(opt string) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	texttemplate.New("").Option(opt)
+	return nil
+}
+
+// options returns the template options pages are parsed with.
+func (r *Reloader) options() []string {
+	if isStrict() {
+		return append(r.templateOptions[:len(r.templateOptions):len(r.templateOptions)],
+			"missingkey=error")
+	}
+	return r.templateOptions
+}
+
+// recordSample remembers data as the sample for key in strict mode.
+func (r *Reloader) recordSample(key string, data interface{}) {
+	if !isStrict() {
+		return
+	}
+	r.samples.mu.Lock()
+	defer r.samples.mu.Unlock()
+	if r.samples.data == nil {
+		r.samples.data = map[string]interface{}{}
+	}
+	r.samples.data[key] = data
+}
+
+// dryRun executes the pages a change to the file name affects against their
+// samples, and returns the first failure. Pages that were never rendered
+// have no sample and are skipped.
+func (r *Reloader) dryRun(name string) error {
+	r.samples.mu.Lock()
+	data := make(map[string]interface{}, len(r.samples.data))
+	for key, d := range r.samples.data {
+		data[key] = d
+	}
+	r.samples.mu.Unlock()
+
+	// A changed partial may break any page.
+	var keys []string
+	if key, ok := r.templateKey(name); ok && !r.isPartial(key) {
+		keys = []string{key}
+	} else {
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	for _, key := range keys {
+		sample, ok := data[key]
+		tmpl := r.lookup(key)
+		if !ok || tmpl == nil {
+			continue
+		}
+		if err := tmpl.Execute(io.Discard, sample); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStrictMissingMapKey(t *testing.T) {
+	files := map[string]string{"index.html": "{{.name}} {{.missing}}"}
+	data := map[string]string{"name": "x"}
+
+	r, _, _ := newTestReloader(t, files)
+	if out := execute(t, r, "index", data); out != "x " {
+		t.Errorf("index = %q without strict mode, want %q", out, "x ")
+	}
+
+	setFlag(t, strict, true)
+	r, _, _ = newTestReloader(t, files)
+	tmpl, err := r.Get("index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Execute(&strings.Builder{}, data); err == nil ||
+		!strings.Contains(err.Error(), "missing") {
+		t.Errorf("executing with a missing key: %v, want an error naming it", err)
+	}
+}
+
+func TestTemplateOptions(t *testing.T) {
+	files := map[string]string{
+		"index.html":  "{{.name}} {{.missing}}",
+		"welcome.txt": "{{.name}} {{.missing}}",
+	}
+	data := map[string]string{"name": "x"}
+	r, _, _ := newTestReloader(t, files, WithEngine(Text, ".txt"))
+	for key, want := range map[string]string{"index": "x ", "welcome": "x <no value>"} {
+		if out := execute(t, r, key, data); out != want {
+			t.Errorf("%s = %q by default, want %q", key, out, want)
+		}
+	}
+
+	r, dir, watchers := newTestReloader(t, files, WithEngine(Text, ".txt"),
+		WithTemplateOptions("missingkey=error"))
+	// Reparsed templates keep the option.
+	edit(t, dir, watchers, map[string]string{
+		"index.html":  "{{.name}}! {{.missing}}",
+		"welcome.txt": "{{.name}}! {{.missing}}",
+	})
+	for _, key := range []string{"index", "welcome"} {
+		tmpl, err := r.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tmpl.Execute(&strings.Builder{}, data); err == nil ||
+			!strings.Contains(err.Error(), "missing") {
+			t.Errorf("executing %s with a missing key: %v, want an error naming it", key, err)
+		}
+	}
+}
+
+func TestStrictDryRunsChangedPage(t *testing.T) {
+	setFlag(t, strict, true)
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "{{.Title}}"})
+	r.recordSample("index", struct{ Title string }{"Home"})
+
+	msg := edit(t, dir, watchers, map[string]string{"index.html": "{{.Titel}}"})
+	if msg.Type != MessageError || !strings.Contains(msg.Error, "Titel") {
+		t.Errorf("got %s message %q, want an error naming Titel", msg.Type, msg.Error)
+	}
+
+	msg = edit(t, dir, watchers, map[string]string{"index.html": "<h1>{{.Title}}</h1>"})
+	if msg.Type != MessageReload {
+		t.Errorf("got %s message %q after the fix, want %s", msg.Type, msg.Error, MessageReload)
+	}
+}
+
+func TestStrictNeverInProduction(t *testing.T) {
+	setFlag(t, strict, true)
+	setFlag(t, production, true)
+	if isStrict() {
+		t.Error("strict mode is on with -prod")
+	}
+	r, _, _ := newTestReloader(t, nil)
+	r.recordSample("index", "data")
+	if len(r.samples.data) != 0 {
+		t.Error("a sample was recorded with -prod")
+	}
+}
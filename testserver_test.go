@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testDelay replaces the debounce, coalesce and settle delays of a
+// TestServer, so changes are handled within milliseconds.
+const testDelay = 5 * time.Millisecond
+
+// TestServer is a Reloader watching a temporary directory, served by an
+// httptest.Server with a client connected to its websocket, to test the
+// whole flow from a template being written to the clients being told to
+// reload:
+//
+//	s := NewTestServer(t)
+//	s.WriteTemplate("index.html", "<h1>Hello</h1>")
+//	s.ExpectReload(time.Second)
+type TestServer struct {
+	*Reloader
+	// Dir is the root templates are written to.
+	Dir string
+	// Server serves Reloader.Handler.
+	Server *httptest.Server
+
+	t      testing.TB
+	client *TestClient
+}
+
+// NewTestServer starts a TestServer over a new temporary directory, with
+// options like WithWatcher on top. It is closed once t's test ends.
+func NewTestServer(t testing.TB, options ...Option) *TestServer {
+	t.Helper()
+	dir := t.TempDir()
+	r := New(append([]Option{Root{Path: dir}}, options...)...)
+	r.Debounce = testDelay
+	r.Coalesce = testDelay
+	r.Settle = testDelay
+	r.Scan()
+	ctx, cancel := context.WithCancel(context.Background())
+	r.Watch(ctx)
+
+	s := &TestServer{
+		Reloader: r,
+		Dir:      dir,
+		Server:   httptest.NewServer(r.Handler()),
+		t:        t,
+	}
+	t.Cleanup(func() {
+		r.Close()
+		cancel()
+		s.Server.Close()
+	})
+	s.client = s.Client()
+	return s
+}
+
+// WriteTemplate writes content to the file name below Dir, creating the
+// directories it's in.
+func (s *TestServer) WriteTemplate(name, content string) {
+	s.t.Helper()
+	path := filepath.Join(s.Dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		s.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// Client returns a new client connected to the websocket. It is closed
+// once the test ends.
+func (s *TestServer) Client() *TestClient {
+	s.t.Helper()
+	c := &TestClient{
+		t:   s.t,
+		url: "ws" + strings.TrimPrefix(s.Server.URL, "http") + wsPath,
+	}
+	c.dial()
+	s.t.Cleanup(c.Close)
+	return c
+}
+
+// ExpectReload waits for the client NewTestServer connected to be told to
+// reload, see TestClient.ExpectReload.
+func (s *TestServer) ExpectReload(timeout time.Duration) Message {
+	s.t.Helper()
+	return s.client.ExpectReload(timeout)
+}
+
+// ExpectError waits for the client NewTestServer connected to be sent an
+// error, see TestClient.ExpectError.
+func (s *TestServer) ExpectError(timeout time.Duration) Message {
+	s.t.Helper()
+	return s.client.ExpectError(timeout)
+}
+
+// ExpectNothing checks that the client NewTestServer connected is sent
+// nothing for d.
+func (s *TestServer) ExpectNothing(d time.Duration) {
+	s.t.Helper()
+	s.client.ExpectNothing(d)
+}
+
+// TestClient is a websocket client of a TestServer, receiving messages
+// like the reload client in a browser does.
+type TestClient struct {
+	t    testing.TB
+	url  string
+	conn *websocket.Conn
+	msgs chan Message
+	// version is that of the last message received.
+	version Version
+	seen    bool
+}
+
+func (c *TestClient) dial() {
+	c.t.Helper()
+	url := c.url
+	if c.seen {
+		url += "?" + VersionParam + "=" + strconv.FormatUint(uint64(c.version), 10)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.conn = conn
+	c.msgs = make(chan Message, 16)
+	go func(msgs chan<- Message) {
+		defer close(msgs)
+		for {
+			var msg Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			msgs <- msg
+		}
+	}(c.msgs)
+}
+
+// Next returns the next message the client is sent, and false if none is
+// within timeout or the connection is closed.
+func (c *TestClient) Next(timeout time.Duration) (Message, bool) {
+	select {
+	case msg, ok := <-c.msgs:
+		if ok {
+			c.version = msg.Version
+			c.seen = true
+		}
+		return msg, ok
+	case <-time.After(timeout):
+		return Message{}, false
+	}
+}
+
+// ExpectReload fails the test unless the next message, sent within
+// timeout, tells the client to reload.
+func (c *TestClient) ExpectReload(timeout time.Duration) Message {
+	c.t.Helper()
+	return c.expect(MessageReload, timeout)
+}
+
+// ExpectError fails the test unless the next message, sent within
+// timeout, reports an error.
+func (c *TestClient) ExpectError(timeout time.Duration) Message {
+	c.t.Helper()
+	return c.expect(MessageError, timeout)
+}
+
+func (c *TestClient) expect(typ string, timeout time.Duration) Message {
+	c.t.Helper()
+	msg, ok := c.Next(timeout)
+	switch {
+	case !ok:
+		c.t.Fatalf("no %s message within %v", typ, timeout)
+	case msg.Type != typ:
+		c.t.Fatalf("got %s message %+v, want %s", msg.Type, msg, typ)
+	}
+	return msg
+}
+
+// ExpectNothing fails the test if the client is sent a message within d.
+func (c *TestClient) ExpectNothing(d time.Duration) {
+	c.t.Helper()
+	if msg, ok := c.Next(d); ok {
+		c.t.Fatalf("got unexpected %s message %+v", msg.Type, msg)
+	}
+}
+
+// Reconnect drops the connection and connects again, passing the version
+// of the last message received like the reload client does.
+func (c *TestClient) Reconnect() {
+	c.t.Helper()
+	c.Close()
+	for range c.msgs {
+	}
+	c.dial()
+}
+
+// Close closes the connection.
+func (c *TestClient) Close() {
+	c.conn.Close()
+}
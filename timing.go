@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSlowParse is how long parsing a template may take before a
+// warning is logged, unless Reloader.SlowParse says otherwise.
+const DefaultSlowParse = 250 * time.Millisecond
+
+// parseHistory is how many parse durations are kept for each template.
+const parseHistory = 10
+
+// maxSlowest is how many templates Stats lists as the slowest to parse.
+const maxSlowest = 10
+
+// ParseTiming describes how long a template took to parse recently.
+type ParseTiming struct {
+	Key  string `json:"key"`
+	Path string `json:"path"`
+	// Count is how many times the template was parsed.
+	Count int           `json:"count"`
+	Last  time.Duration `json:"last"`
+	// Max is the longest of the last parses.
+	Max time.Duration `json:"max"`
+}
+
+// parseTimes keeps the durations of the last parses of each template.
+type parseTimes struct {
+	mu     sync.Mutex
+	byKey  map[string][]time.Duration
+	counts map[string]int
+	paths  map[string]string
+}
+
+// add records that parsing key from path took d.
+func (t *parseTimes) add(key, path string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.byKey == nil {
+		t.byKey = map[string][]time.Duration{}
+		t.counts = map[string]int{}
+		t.paths = map[string]string{}
+	}
+	times := append(t.byKey[key], d)
+	if len(times) > parseHistory {
+		times = times[len(times)-parseHistory:]
+	}
+	t.byKey[key] = times
+	t.counts[key]++
+	t.paths[key] = path
+}
+
+// slowest returns the timings of the n templates slowest to parse.
+func (t *parseTimes) slowest(n int) []ParseTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timings := make([]ParseTiming, 0, len(t.byKey))
+	for key, times := range t.byKey {
+		timing := ParseTiming{
+			Key:   key,
+			Path:  t.paths[key],
+			Count: t.counts[key],
+			Last:  times[len(times)-1],
+		}
+		for _, d := range times {
+			timing.Max = max(timing.Max, d)
+		}
+		timings = append(timings, timing)
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		if timings[i].Max != timings[j].Max {
+			return timings[i].Max > timings[j].Max
+		}
+		return timings[i].Key < timings[j].Key
+	})
+	return timings[:min(n, len(timings))]
+}
+
+// timeParse records how long parsing the template at path took since
+// start, warning if it was slow.
+func (r *Reloader) timeParse(path string, start time.Time) {
+	d := time.Since(start)
+	key, ok := r.templateKey(path)
+	if !ok {
+		key = path
+	}
+	r.parseTimes.add(key, path, d)
+	slow := r.SlowParse
+	if slow == 0 {
+		slow = DefaultSlowParse
+	}
+	if d > slow {
+		r.errors.printf("Warning: parsing %s took %v.\n", path, d.Truncate(time.Microsecond))
+	}
+}
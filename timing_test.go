@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowParseWarning(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"index.html": "index",
+		"about.html": "about",
+	})
+	r.SlowParse = time.Nanosecond
+	var log lockedBuffer
+	r.errors.out = &log
+
+	edit(t, dir, watchers, map[string]string{"index.html": "index2"})
+	path := filepath.Join(dir, "index.html")
+	if got := log.String(); !strings.Contains(got, "Warning: parsing "+path+" took ") {
+		t.Errorf("logged %q, want a warning naming %s", got, path)
+	}
+
+	timings := map[string]ParseTiming{}
+	for _, timing := range r.Stats().SlowestTemplates {
+		timings[timing.Key] = timing
+	}
+	index, about := timings["index"], timings["about"]
+	if index.Count != 2 || index.Path != path {
+		t.Errorf("index timing = %+v, want 2 parses of %s", index, path)
+	}
+	if index.Last <= 0 || index.Max < index.Last {
+		t.Errorf("index timing = %+v, want Last and Max recorded", index)
+	}
+	if about.Count != 1 {
+		t.Errorf("about timing = %+v, want 1 parse", about)
+	}
+}
+
+func TestParseTimesKeepHistory(t *testing.T) {
+	var times parseTimes
+	times.add("index", "index.html", time.Second)
+	for i := 0; i < parseHistory; i++ {
+		times.add("index", "index.html", time.Millisecond)
+	}
+	times.add("about", "about.html", 2*time.Millisecond)
+	slowest := times.slowest(1)
+	if len(slowest) != 1 {
+		t.Fatalf("slowest(1) = %v", slowest)
+	}
+	// The one second parse fell out of the history.
+	want := ParseTiming{"about", "about.html", 1, 2 * time.Millisecond, 2 * time.Millisecond}
+	if slowest[0] != want {
+		t.Errorf("slowest(1) = %+v, want %+v", slowest[0], want)
+	}
+	if all := times.slowest(maxSlowest); len(all) != 2 || all[1].Count != parseHistory+1 {
+		t.Errorf("slowest = %+v, want index counted %d times", all, parseHistory+1)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/template/parse"
+	"time"
+)
+
+const (
+	// maxTrackedPages bounds how many request paths usage is recorded for;
+	// the least recently requested path is forgotten first.
+	maxTrackedPages = 256
+
+	// usageTTL is how long a path is remembered after its last request.
+	usageTTL = 30 * time.Minute
+)
+
+// pageUsage records the template keys one request path rendered.
+type pageUsage struct {
+	path     string
+	keys     []string
+	lastUsed time.Time
+}
+
+// usageLog learns from actual traffic which templates each URL path uses,
+// keeping the most recently requested paths.
+type usageLog struct {
+	mu    sync.Mutex
+	order *list.List
+	pages map[string]*list.Element
+	// cycles holds the templates of each page found including
+	// themselves, so they're reported once per parse rather than on every
+	// request.
+	cycles map[string]map[string]bool
+}
+
+// recordUsage remembers that rendering key served path.
+func (r *Reloader) recordUsage(path, key string) {
+	keys := r.templatesUsed(key)
+	u := &r.usage
+	now := time.Now()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.pages == nil {
+		u.order = list.New()
+		u.pages = map[string]*list.Element{}
+	}
+	if e, ok := u.pages[path]; ok {
+		e.Value = &pageUsage{path, keys, now}
+		u.order.MoveToFront(e)
+	} else {
+		u.pages[path] = u.order.PushFront(&pageUsage{path, keys, now})
+	}
+	for u.order.Len() > maxTrackedPages {
+		u.forget(u.order.Back())
+	}
+	u.decay(now)
+}
+
+func (u *usageLog) forget(e *list.Element) {
+	u.order.Remove(e)
+	delete(u.pages, e.Value.(*pageUsage).path)
+}
+
+// decay forgets paths not requested within usageTTL.
+func (u *usageLog) decay(now time.Time) {
+	for e := u.order.Back(); e != nil; e = u.order.Back() {
+		if now.Sub(e.Value.(*pageUsage).lastUsed) < usageTTL {
+			return
+		}
+		u.forget(e)
+	}
+}
+
+// PagesUsing returns the request paths that recently rendered key, either
+// directly or through {{template}}.
+func (r *Reloader) PagesUsing(key string) []string {
+	u := &r.usage
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.pages == nil {
+		return nil
+	}
+	u.decay(time.Now())
+
+	var paths []string
+	for path, e := range u.pages {
+		for _, k := range e.Value.(*pageUsage).keys {
+			if k == key {
+				paths = append(paths, path)
+				break
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Usage returns the template keys each recently requested path rendered.
+func (r *Reloader) Usage() map[string][]string {
+	u := &r.usage
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	usage := map[string][]string{}
+	for path, e := range u.pages {
+		usage[path] = append([]string(nil), e.Value.(*pageUsage).keys...)
+	}
+	return usage
+}
+
+// affectedPages returns the request paths a change to the file name should
+// reload when -targeted is set, or nil to reload every page.
+func (r *Reloader) affectedPages(name string) []string {
+	if !*targeted {
+		return nil
+	}
+	key, ok := r.templateKey(filepath.Clean(name))
+	if !ok {
+		return nil
+	}
+	return r.PagesUsing(key)
+}
+
+// templatesUsed returns key and the keys of the partials its template
+// reaches through {{template}} calls. Templates invoked by names computed
+// at runtime can't be detected.
+func (r *Reloader) templatesUsed(key string) []string {
+	tmpl, o := r.outline(key)
+	if tmpl == nil {
+		return []string{key}
+	}
+
+	// Partials are known by file name inside the template set, which
+	// partials in different directories may share.
+	r.RLock()
+	byPath := make(map[string]string, len(r.partials))
+	for k, path := range r.partials {
+		byPath[path] = k
+	}
+	r.RUnlock()
+
+	keys := []string{key}
+	seen := map[string]bool{}
+	// active holds the templates being visited, to notice recursion.
+	active := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if active[name] {
+			r.cycle(key, name)
+		}
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		active[name] = true
+		defer delete(active, name)
+		t, ok := o[name]
+		if !ok {
+			return
+		}
+		for path, k := range byPath {
+			if filepath.Base(path) == t.parseName && k != key {
+				keys = append(keys, k)
+				delete(byPath, path)
+			}
+		}
+		for _, ref := range t.refs {
+			visit(ref)
+		}
+	}
+	visit(tmpl.Name())
+	if b, ok := tmpl.(blockTemplate); ok {
+		visit(b.block)
+	}
+	return keys
+}
+
+// cycle reports that the template name used by key includes itself, unless
+// it was since key was last parsed.
+func (r *Reloader) cycle(key, name string) {
+	u := &r.usage
+	u.mu.Lock()
+	if u.cycles == nil {
+		u.cycles = map[string]map[string]bool{}
+	}
+	if u.cycles[key] == nil {
+		u.cycles[key] = map[string]bool{}
+	}
+	reported := u.cycles[key][name]
+	u.cycles[key][name] = true
+	u.mu.Unlock()
+	if !reported {
+		r.errors.print(fmt.Errorf("Template %s of %s includes itself", name, key))
+	}
+}
+
+// forgetCycles has the cycles of key reported again, once it's reparsed.
+func (r *Reloader) forgetCycles(key string) {
+	u := &r.usage
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.cycles, key)
+}
+
+// templateRefs returns the names of the templates node invokes.
+func templateRefs(node parse.Node) []string {
+	var refs []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			refs = append(refs, templateRefs(child)...)
+		}
+	case *parse.TemplateNode:
+		refs = append(refs, n.Name)
+	case *parse.IfNode:
+		refs = append(refs, templateRefs(n.List)...)
+		refs = append(refs, templateRefs(n.ElseList)...)
+	case *parse.RangeNode:
+		refs = append(refs, templateRefs(n.List)...)
+		refs = append(refs, templateRefs(n.ElseList)...)
+	case *parse.WithNode:
+		refs = append(refs, templateRefs(n.List)...)
+		refs = append(refs, templateRefs(n.ElseList)...)
+	}
+	return refs
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDependenciesOfPartialsSharingAName(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"a/_nav.html": `a`,
+		"b/_nav.html": `b`,
+		"index.html":  `{{template "_nav.html"}}`,
+		"about.html":  `about`,
+	})
+	if deps, want := r.Dependencies()["index"], []string{"a/_nav", "b/_nav"}; !slices.Equal(deps, want) {
+		t.Errorf("index depends on %v, want %v", deps, want)
+	}
+
+	edit(t, dir, watchers, map[string]string{"a/_nav.html": "a2"})
+	if v, _ := r.Version("index"); v != 2 {
+		t.Errorf("Version(index) = %d once a/_nav changed, want 2", v)
+	}
+	if v, _ := r.Version("about"); v != 1 {
+		t.Errorf("Version(about) = %d, want 1 as it doesn't include the nav", v)
+	}
+}
+
+func TestCycleIsReported(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "index"})
+	errs := r.ErrorLog()
+	cyclic := `{{define "loop"}}{{template "loop" .}}{{end}}{{if false}}{{template "loop"}}{{end}}`
+	for i := 0; i < 2; i++ {
+		edit(t, dir, watchers, map[string]string{"index.html": cyclic + strings.Repeat(" ", i)})
+		select {
+		case err := <-errs:
+			if !strings.Contains(err.Error(), "loop of index includes itself") {
+				t.Errorf("got error %v, want the cycle reported", err)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("parse %d: the cycle wasn't reported", i+1)
+		}
+	}
+}
+
+func TestUsageOfRenderedPage(t *testing.T) {
+	r, _, _ := newTestReloader(t, map[string]string{
+		"_title.html": `home`,
+		"index.html":  `<a title="{{template "_title.html"}}">index</a>`,
+	})
+	// Executing the page has html/template rename the partial it invokes
+	// after the context it's in.
+	execute(t, r, "index", nil)
+	r.recordUsage("/", "index")
+	if keys, want := r.Usage()["/"], []string{"index", "_title"}; !slices.Equal(keys, want) {
+		t.Errorf("/ used %v, want %v", keys, want)
+	}
+}
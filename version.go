@@ -0,0 +1,26 @@
+package main
+
+// Version numbers the messages sent to clients. It wraps around to zero on
+// overflow, and comparisons use serial number arithmetic (RFC 1982) so they
+// stay correct across the wrap as long as the versions compared are less
+// than half the range apart. It is 32 bits wide so clients can do the same
+// arithmetic exactly with JavaScript numbers.
+type Version uint32
+
+// Next returns the version following v.
+func (v Version) Next() Version {
+	return v + 1
+}
+
+// Before reports whether v is older than o.
+func (v Version) Before(o Version) bool {
+	return int32(o-v) > 0
+}
+
+// Since returns how many versions v is ahead of o, or zero if it is not.
+func (v Version) Since(o Version) uint32 {
+	if !o.Before(v) {
+		return 0
+	}
+	return uint32(v - o)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestVersionNextWraps(t *testing.T) {
+	if v := Version(math.MaxUint32).Next(); v != 0 {
+		t.Errorf("MaxUint32.Next() = %d, want 0", v)
+	}
+	if v := Version(254).Next(); v != 255 {
+		t.Errorf("254.Next() = %d, want 255", v)
+	}
+	if v := Version(255).Next(); v != 256 {
+		t.Errorf("255.Next() = %d, want 256", v)
+	}
+}
+
+func TestVersionBefore(t *testing.T) {
+	const max = Version(math.MaxUint32)
+	tests := []struct {
+		v, o Version
+		want bool
+	}{
+		{0, 1, true},
+		{1, 0, false},
+		{5, 5, false},
+		{max, 0, true},
+		{0, max, false},
+		{max - 1, 2, true},
+		{2, max - 1, false},
+		// Versions half the range apart or more are taken as having
+		// wrapped the other way.
+		{0, 1<<31 - 1, true},
+		{0, 1 << 31, false},
+	}
+	for _, tt := range tests {
+		if got := tt.v.Before(tt.o); got != tt.want {
+			t.Errorf("%d.Before(%d) = %v, want %v", tt.v, tt.o, got, tt.want)
+		}
+	}
+}
+
+func TestVersionSince(t *testing.T) {
+	const max = Version(math.MaxUint32)
+	tests := []struct {
+		v, o Version
+		want uint32
+	}{
+		{3, 1, 2},
+		{1, 3, 0},
+		{4, 4, 0},
+		{1, max, 2},
+		{max, 1, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.v.Since(tt.o); got != tt.want {
+			t.Errorf("%d.Since(%d) = %d, want %d", tt.v, tt.o, got, tt.want)
+		}
+	}
+}
+
+func TestVersionMissedAcrossWrap(t *testing.T) {
+	// A client that last saw the version before the counter wrapped
+	// still knows it missed the ones after.
+	seen := Version(math.MaxUint32 - 1)
+	current := seen
+	for i := 0; i < 3; i++ {
+		current = current.Next()
+	}
+	if !seen.Before(current) || current.Since(seen) != 3 {
+		t.Errorf("seen %d, current %d: Before = %v, Since = %d, want true and 3",
+			seen, current, seen.Before(current), current.Since(seen))
+	}
+}
+
+func TestTemplateVersions(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "one"})
+	version := func() uint64 {
+		t.Helper()
+		v, ok := r.Version("index")
+		if !ok {
+			t.Fatal("index has no version")
+		}
+		return v
+	}
+	if v := version(); v != 1 {
+		t.Errorf("Version(index) = %d once loaded, want 1", v)
+	}
+
+	msg := edit(t, dir, watchers, map[string]string{"index.html": "two"})
+	if v := version(); v != 2 {
+		t.Errorf("Version(index) = %d once edited, want 2", v)
+	}
+	if msg.Templates["index"] != 2 {
+		t.Errorf("message lists versions %v, want index at 2", msg.Templates)
+	}
+	edit(t, dir, watchers, map[string]string{"index.html": "{{if}}"})
+	if v := version(); v != 2 {
+		t.Errorf("Version(index) = %d after failing to parse, want 2", v)
+	}
+
+	// A removed template loses its version, and starts again at 1.
+	path := filepath.Join(dir, "index.html")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	since := currentVersion()
+	watchers.last().Send(path, fsnotify.Remove)
+	published(t, since)
+	if v, ok := r.Version("index"); ok {
+		t.Errorf("Version(index) = %d once removed, want none", v)
+	}
+	writeFiles(t, dir, map[string]string{"index.html": "back"})
+	since = currentVersion()
+	watchers.last().Send(path, fsnotify.Create)
+	published(t, since)
+	if v := version(); v != 1 {
+		t.Errorf("Version(index) = %d once recreated, want 1", v)
+	}
+}
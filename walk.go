@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// walkOptions controls walks over directory trees.
+type walkOptions struct {
+	// skip leaves out the files and directories it returns true for,
+	// along with everything below the directories.
+	skip func(string) bool
+	// tooDeep leaves out the directories it returns true for.
+	tooDeep func(string) bool
+	// follow descends into symlinked directories.
+	follow bool
+}
+
+// WithFollowSymlinks descends into symlinked directories below the roots,
+// like "templates/shared -> ../../design-system/templates", watching them
+// and loading their templates under the symlink's path, so the link's
+// target provides "shared/button". Links leading back into a directory
+// already walked are skipped.
+func WithFollowSymlinks() Option {
+	return optionFunc(func(r *Reloader) { r.followSymlinks = true })
+}
+
+func (r *Reloader) walkOptions() walkOptions {
+	return walkOptions{skip: r.isExcluded, tooDeep: r.tooDeep, follow: r.followSymlinks}
+}
+
+// walkDir walks the tree at root like filepath.WalkDir, applying opts.
+func (opts walkOptions) walkDir(root string, fn fs.WalkDirFunc) {
+	root = filepath.Clean(root)
+	visited := map[string]bool{}
+
+	var walkTree func(top string)
+	walkTree = func(top string) {
+		filepath.WalkDir(top, func(path string, d fs.DirEntry, err error) error {
+			path = filepath.Clean(path)
+			if err != nil {
+				return fn(path, d, err)
+			}
+			if opts.skip != nil && path != root && opts.skip(path) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() && opts.tooDeep != nil && path != root && opts.tooDeep(path) {
+				return filepath.SkipDir
+			}
+			if !opts.follow {
+				return fn(path, d, nil)
+			}
+
+			if d.IsDir() {
+				real, err := filepath.EvalSymlinks(path)
+				if err == nil && visited[real] {
+					debugf("Not following %s again, it leads to %s.\n", path, real)
+					return filepath.SkipDir
+				}
+				visited[real] = true
+			} else if d.Type()&fs.ModeSymlink != 0 {
+				if info, err := os.Stat(path); err == nil && info.IsDir() {
+					// The trailing separator makes WalkDir resolve the
+					// link instead of reporting it.
+					walkTree(path + string(filepath.Separator))
+					return nil
+				}
+			}
+			return fn(path, d, nil)
+		})
+	}
+	walkTree(root)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// symlink links newname to oldname, skipping the test where symlinks
+// aren't supported.
+func symlink(t *testing.T, oldname, newname string) {
+	t.Helper()
+	if err := os.Symlink(oldname, newname); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+}
+
+func TestFollowSymlinkedDirectory(t *testing.T) {
+	shared := t.TempDir()
+	writeFiles(t, shared, map[string]string{"button.html": "<button>one</button>"})
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"index.html": `{{template "shared/button.html"}}`})
+	symlink(t, shared, filepath.Join(dir, "shared"))
+	r, watchers := startTestReloader(t, Root{Path: dir}, WithFollowSymlinks())
+
+	if out := execute(t, r, "shared/button", nil); out != "<button>one</button>" {
+		t.Errorf("shared/button = %q, want the linked template", out)
+	}
+	link := filepath.Join(dir, "shared")
+	if !watchers.last().Watched(link) {
+		t.Errorf("%s isn't watched", link)
+	}
+
+	// Changes behind the link are reported under its path.
+	edit(t, link, watchers, map[string]string{"button.html": "<button>two</button>"})
+	if out := execute(t, r, "shared/button", nil); out != "<button>two</button>" {
+		t.Errorf("shared/button = %q once edited, want two", out)
+	}
+}
+
+func TestSymlinkCreatedWhileWatching(t *testing.T) {
+	shared := t.TempDir()
+	writeFiles(t, shared, map[string]string{"button.html": "<button>"})
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "index"},
+		WithFollowSymlinks())
+
+	link := filepath.Join(dir, "shared")
+	symlink(t, shared, link)
+	since := currentVersion()
+	watchers.last().Send(link, fsnotify.Create)
+	published(t, since)
+	if !watchers.last().Watched(link) {
+		t.Errorf("%s isn't watched once created", link)
+	}
+	if out := execute(t, r, "shared/button", nil); out != "<button>" {
+		t.Errorf("shared/button = %q, want the linked template", out)
+	}
+}
+
+func TestCyclicSymlinkTerminates(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.html":  "index",
+		"a/page.html": "page",
+	})
+	// a/loop leads back to the root, and a/self to a itself.
+	symlink(t, dir, filepath.Join(dir, "a", "loop"))
+	symlink(t, ".", filepath.Join(dir, "a", "self"))
+
+	_, files := walk([]string{dir}, walkOptions{follow: true})
+	var found []string
+	for _, list := range files {
+		for _, f := range list {
+			rel, _ := filepath.Rel(dir, f.path)
+			found = append(found, filepath.ToSlash(rel))
+		}
+	}
+	slices.Sort(found)
+	if want := []string{"a/page.html", "index.html"}; !slices.Equal(found, want) {
+		t.Errorf("walked %v, want %v", found, want)
+	}
+
+	r, _ := startTestReloader(t, Root{Path: dir}, WithFollowSymlinks())
+	if names, want := r.Names(), []string{"a/page", "index"}; !slices.Equal(names, want) {
+		t.Errorf("Names() = %v, want %v", names, want)
+	}
+}
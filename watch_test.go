@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/goleak"
+)
+
+func TestWatchStopsOnCancel(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"index.html": "one"})
+	watchers := &fakeWatchers{}
+	r := New(Root{Path: dir}, watchers.option())
+	r.Debounce = testDelay
+	r.Coalesce = testDelay
+	r.Settle = testDelay
+	r.Scan()
+	ctx, cancel := context.WithCancel(context.Background())
+	r.Watch(ctx)
+
+	// Events are still in flight when the context is cancelled.
+	path := filepath.Join(dir, "index.html")
+	writeFiles(t, dir, map[string]string{"index.html": "two"})
+	for i := 0; i < 3; i++ {
+		watchers.last().Send(path, fsnotify.Write)
+	}
+	cancel()
+	cancel()
+	waitFor(t, "the Reloader to stop", r.isStopped)
+
+	since := currentVersion()
+	r.change(ChangeEvent{path, fsnotify.Write})
+	time.Sleep(20 * testDelay)
+	if v := currentVersion(); v != since {
+		t.Errorf("%d messages published once stopped", v.Since(since))
+	}
+	if _, err := r.Get("index"); err != nil {
+		t.Errorf("Get(index) once stopped: %v", err)
+	}
+}
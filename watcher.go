@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// Delay before recreating a dead watcher. It doubles with every
+	// consecutive restart, up to maxRestartDelay.
+	restartDelay    = 100 * time.Millisecond
+	maxRestartDelay = 30 * time.Second
+
+	// Give up on live reload after this many consecutive restarts.
+	maxWatcherRestarts = 10
+
+	// A watcher that stayed alive this long resets the restart count.
+	watcherHealthyAfter = time.Minute
+)
+
+// addTree watches dir and every directory below it, since fsnotify only
+// reports changes to files directly inside watched directories. It returns
+// the directories now watched, and reports those that can't be in the
+// error.
+func addTree(watcher Watcher, dir string, opts walkOptions) ([]string, error) {
+	var dirs []string
+	var errs []error
+	opts.walkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if err := watcher.Add(path); err != nil {
+			errs = append(errs, &watchError{filepath.Clean(path), err})
+			return nil
+		}
+		dirs = append(dirs, filepath.Clean(path))
+		return nil
+	})
+	return dirs, errors.Join(errs...)
+}
+
+// watching records dirs as watched, or as removed if add is false.
+// Removed directories are remembered, as their removal is reported both by
+// their own watch and by their parent's.
+func (r *Reloader) watching(add bool, dirs ...string) {
+	r.Lock()
+	defer r.Unlock()
+	if r.dirs == nil {
+		r.dirs = map[string]bool{}
+	}
+	for _, dir := range dirs {
+		r.dirs[dir] = add
+	}
+}
+
+// isDir reports whether name is, or was until removed, a watched directory.
+func (r *Reloader) isDir(name string) bool {
+	r.RLock()
+	defer r.RUnlock()
+	_, ok := r.dirs[filepath.Clean(name)]
+	return ok
+}
+
+// watchDirs keeps the watcher in step with directories created or removed
+// below the watched ones. Files may be written into a new directory before
+// its watch is in place, so those already there are queued as changes.
+// It reports whether evt was about a directory, leaving nothing else to do.
+func (r *Reloader) watchDirs(evt fsnotify.Event) bool {
+	r.RLock()
+	watcher := r.Watcher
+	r.RUnlock()
+
+	if evt.Has(fsnotify.Remove) || evt.Has(fsnotify.Rename) {
+		if !r.isDir(evt.Name) {
+			return false
+		}
+		// The watch may be gone with the directory already.
+		watcher.Remove(evt.Name)
+		r.watching(false, filepath.Clean(evt.Name))
+		debugf("Directory %s removed; no longer watching it.\n", evt.Name)
+		return true
+	}
+	if !evt.Has(fsnotify.Create) {
+		return false
+	}
+	if info, err := os.Stat(evt.Name); err != nil || !info.IsDir() {
+		// A file took the place of a removed directory.
+		r.Lock()
+		delete(r.dirs, filepath.Clean(evt.Name))
+		r.Unlock()
+		return false
+	}
+
+	if r.tooDeep(evt.Name) {
+		debugf("Directory %s created below the depth limit; ignored.\n", evt.Name)
+		return true
+	}
+	fmt.Printf("Directory %s created; watching it.\n", evt.Name)
+	if err := r.watchTree(watcher, evt.Name); err != nil {
+		r.errors.print(err)
+	}
+	_, files := walk([]string{evt.Name}, r.walkOptions())
+	for _, list := range files {
+		for _, f := range list {
+			r.change(ChangeEvent{f.path, fsnotify.Create})
+		}
+	}
+	return true
+}
+
+// overflowed rescans everything after the watcher's event queue overflowed,
+// since there's no telling which changes were lost.
+func (r *Reloader) overflowed() {
+	fmt.Printf("Too many changes at once; rescanning %v.\n", r.rootPaths())
+	r.changes.handling.Lock()
+	defer r.changes.handling.Unlock()
+	r.Scan()
+	publish(Message{Type: MessageReload})
+}
+
+// recoverWatcher replaces a watcher that died or reported an error with a
+// fresh one watching the same roots, and reloads any file that changed
+// while nothing was watching. restarts counts consecutive restarts and is
+// used for the backoff. It returns false once it gives up or ctx is done.
+func (r *Reloader) recoverWatcher(ctx context.Context, started time.Time, restarts *int) bool {
+	if time.Since(started) > watcherHealthyAfter {
+		*restarts = 0
+	}
+	fmt.Println("Watcher died or failed; recreating it.")
+
+	for {
+		if *restarts >= maxWatcherRestarts {
+			fmt.Printf("Watcher failed %d times in a row; giving up. "+
+				"Live reload is disabled until restart.\n", *restarts)
+			return false
+		}
+
+		delay := restartDelay << *restarts
+		if delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return false
+		}
+		*restarts++
+
+		if err := r.restartWatcher(); err != nil {
+			fmt.Printf("Unable to recreate watcher (attempt %d): %v\n",
+				*restarts, err)
+			continue
+		}
+		break
+	}
+
+	fmt.Printf("Watcher recreated (attempt %d); rescanning %v.\n",
+		*restarts, r.rootPaths())
+	r.rescan()
+	return true
+}
+
+// restartWatcher closes the current watcher and replaces it with a new one
+// watching all roots. Directories that can't be watched are reported, but
+// don't fail the restart unless it's a root that's still there. Roots that
+// disappeared are reported and the rest are watched.
+func (r *Reloader) restartWatcher() error {
+	r.Watcher.Close()
+
+	watcher, err := r.newWatcher()
+	if err != nil {
+		return err
+	}
+	for _, dir := range append(r.rootDirs(), r.static...) {
+		if err := watcher.Add(dir); err != nil {
+			if _, statErr := os.Stat(dir); errors.Is(statErr, fs.ErrNotExist) {
+				fmt.Printf("Root %s is missing; watching the others.\n", dir)
+				continue
+			}
+			watcher.Close()
+			return err
+		}
+	}
+	r.Lock()
+	r.dirs = nil
+	r.Unlock()
+	for _, dir := range append(r.rootPaths(), r.static...) {
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := r.watchTree(watcher, dir); err != nil {
+			r.errors.print(err)
+		}
+	}
+	for _, dir := range r.assetDirs() {
+		if err := watcher.Add(dir); err != nil {
+			r.errors.print(err)
+		}
+	}
+	if r.locales != nil {
+		if err := watcher.Add(r.locales.dir); err != nil {
+			r.errors.print(err)
+		}
+	}
+
+	r.Lock()
+	if r.closed {
+		r.Unlock()
+		watcher.Close()
+		return errReloaderClosed
+	}
+	r.Watcher = watcher
+	r.Unlock()
+	atomic.AddUint64(&r.stats.WatcherRestarts, 1)
+	return nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestWatcherErrorRecreatesWatcher(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "one"})
+	first := watchers.last()
+	first.SendError(errors.New("inotify: bad file descriptor"))
+	waitFor(t, "the watcher to be recreated", func() bool {
+		return r.Stats().WatcherRestarts == 1
+	})
+	if watchers.count() != 2 {
+		t.Fatalf("%d watchers created, want 2", watchers.count())
+	}
+	second := watchers.last()
+	if !second.Watched(dir) {
+		t.Errorf("replacement isn't watching the root %s", dir)
+	}
+
+	// Changes reported by the replacement are handled.
+	since := currentVersion()
+	writeFiles(t, dir, map[string]string{"index.html": "two"})
+	second.Send(filepath.Join(dir, "index.html"), fsnotify.Write)
+	if msg := published(t, since); msg.Type != MessageReload {
+		t.Errorf("got %s message, want %s", msg.Type, MessageReload)
+	}
+	if out := execute(t, r, "index", nil); out != "two" {
+		t.Errorf("index = %q, want two", out)
+	}
+}
+
+func TestWatchesNestedDirectories(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.html":                "index",
+		"admin/users/list.html":     "list",
+		"partials/emails/_nav.html": "nav",
+	})
+	r := New(Root{Path: dir})
+	r.Debounce, r.Coalesce, r.Settle = testDelay, testDelay, testDelay
+	r.Scan()
+	r.Watch(context.Background())
+	defer r.Close()
+
+	since := currentVersion()
+	writeFiles(t, dir, map[string]string{"admin/users/list.html": "list2"})
+	published(t, since)
+	if out := execute(t, r, "admin/users/list", nil); out != "list2" {
+		t.Errorf("admin/users/list = %q, want list2", out)
+	}
+}
+
+func TestWatchesEveryDirectory(t *testing.T) {
+	_, dir, watchers := newTestReloader(t, map[string]string{
+		"a/b/c.html": "c",
+		"d/e.html":   "e",
+	})
+	for _, sub := range []string{"", "a", "a/b", "d"} {
+		if !watchers.last().Watched(filepath.Join(dir, sub)) {
+			t.Errorf("%s isn't watched", filepath.Join(dir, sub))
+		}
+	}
+}
+
+func TestAddTreeReportsFailures(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a/index.html": "index"})
+	w := NewFakeWatcher()
+	w.Close()
+	dirs, err := addTree(w, dir, walkOptions{})
+	if len(dirs) != 0 || err == nil || !strings.Contains(err.Error(), filepath.Join(dir, "a")) {
+		t.Errorf("addTree on a closed watcher = %v, %v; want an error naming %s",
+			dirs, err, filepath.Join(dir, "a"))
+	}
+}
+
+func TestWatchesCreatedDirectories(t *testing.T) {
+	s := NewTestServer(t)
+	s.WriteTemplate("emails/welcome.html", "<p>welcome</p>")
+	s.ExpectReload(testTimeout)
+	if _, err := s.Get("emails/welcome"); err != nil {
+		t.Fatal(err)
+	}
+	// Later changes in the new directory are seen too.
+	s.WriteTemplate("emails/welcome.html", "<p>welcome back</p>")
+	s.ExpectReload(testTimeout)
+	if v, _ := s.Version("emails/welcome"); v != 2 {
+		t.Errorf("Version(emails/welcome) = %d, want 2", v)
+	}
+}
+
+func TestUnwatchesRemovedDirectories(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, nil)
+	sub := filepath.Join(dir, "emails")
+	writeFiles(t, dir, map[string]string{"emails/welcome.html": "welcome"})
+	since := currentVersion()
+	watchers.last().Send(sub, fsnotify.Create)
+	waitFor(t, "the new directory to be watched", func() bool {
+		return watchers.last().Watched(sub)
+	})
+	// Files written before the watch was in place are picked up.
+	published(t, since)
+	if _, err := r.Get("emails/welcome"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.RemoveAll(sub); err != nil {
+		t.Fatal(err)
+	}
+	watchers.last().Send(sub, fsnotify.Remove)
+	waitFor(t, "the removed directory to be unwatched", func() bool {
+		return !watchers.last().Watched(sub)
+	})
+}
+
+func TestClosedWatcherIsRecreated(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{"index.html": "one"})
+	watchers.last().Close()
+	waitFor(t, "the watcher to be recreated", func() bool {
+		return r.Stats().WatcherRestarts == 1
+	})
+
+	since := currentVersion()
+	writeFiles(t, dir, map[string]string{"index.html": "two"})
+	watchers.last().Send(filepath.Join(dir, "index.html"), fsnotify.Write)
+	published(t, since)
+	if out := execute(t, r, "index", nil); out != "two" {
+		t.Errorf("index = %q, want two", out)
+	}
+}
+
+func TestOverflowRescans(t *testing.T) {
+	r, dir, watchers := newTestReloader(t, map[string]string{
+		"index.html": "one",
+		"about.html": "about",
+	})
+	// Changes the overflow lost.
+	writeFiles(t, dir, map[string]string{"index.html": "two", "new.html": "new"})
+	if err := os.Remove(filepath.Join(dir, "about.html")); err != nil {
+		t.Fatal(err)
+	}
+
+	since := currentVersion()
+	watchers.last().SendError(fsnotify.ErrEventOverflow)
+	if msg := published(t, since); msg.Type != MessageReload {
+		t.Errorf("got %s message, want %s", msg.Type, MessageReload)
+	}
+	if n := currentVersion().Since(since); n != 1 {
+		t.Errorf("%d messages published, want 1", n)
+	}
+	if want := []string{"index", "new"}; !slices.Equal(r.Names(), want) {
+		t.Errorf("Names() = %v, want %v", r.Names(), want)
+	}
+	if out := execute(t, r, "index", nil); out != "two" {
+		t.Errorf("index = %q, want two", out)
+	}
+	if r.Stats().WatcherRestarts != 0 {
+		t.Error("an overflow recreated the watcher")
+	}
+}
+
+func TestWatcherCreationFailureIsRetried(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"index.html": "one"})
+	var mu sync.Mutex
+	var created *FakeWatcher
+	attempts := 0
+	r, _ := startTestReloader(t, Root{Path: dir}, WithWatcher(func() (Watcher, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if attempts++; attempts == 1 {
+			return nil, errors.New("too many open files")
+		}
+		created = NewFakeWatcher()
+		return created, nil
+	}))
+	waitFor(t, "the watcher to be created", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return created != nil && created.Watched(dir)
+	})
+
+	since := currentVersion()
+	writeFiles(t, dir, map[string]string{"index.html": "two"})
+	created.Send(filepath.Join(dir, "index.html"), fsnotify.Write)
+	published(t, since)
+	if out := execute(t, r, "index", nil); out != "two" {
+		t.Errorf("index = %q, want two", out)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"l1/page.html":             "1",
+		"l1/l2/page.html":          "2",
+		"l1/l2/l3/page.html":       "3",
+		"l1/l2/l3/l4/l5/page.html": "5",
+	})
+	r, watchers := startTestReloader(t, Root{Path: dir, MaxDepth: 2})
+	for sub, want := range map[string]bool{
+		"": true, "l1": true, "l1/l2": true, "l1/l2/l3": false, "l1/l2/l3/l4": false,
+	} {
+		if got := watchers.last().Watched(filepath.Join(dir, sub)); got != want {
+			t.Errorf("Watched(%s) = %v, want %v", sub, got, want)
+		}
+	}
+	if want := []string{"l1/l2/page", "l1/page"}; !slices.Equal(r.Names(), want) {
+		t.Errorf("Names() = %v, want %v", r.Names(), want)
+	}
+
+	edit(t, dir, watchers, map[string]string{"l1/l2/page.html": "2 edited"})
+	since := currentVersion()
+	writeFiles(t, dir, map[string]string{"l1/l2/l3/page.html": "3 edited"})
+	watchers.last().Send(filepath.Join(dir, "l1/l2/l3/page.html"), fsnotify.Write)
+	time.Sleep(20 * testDelay)
+	if v := currentVersion(); v != since {
+		t.Errorf("an edit below the depth limit published %d messages", v.Since(since))
+	}
+
+	if _, err := r.Get("l1/l2/l3/page"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Get(l1/l2/l3/page) = %v, want ErrTemplateNotFound", err)
+	}
+
+	// Nor is a directory created below the limit watched.
+	writeFiles(t, dir, map[string]string{"l1/l2/new/page.html": "new"})
+	watchers.last().Send(filepath.Join(dir, "l1/l2/new"), fsnotify.Create)
+	time.Sleep(20 * testDelay)
+	if watchers.last().Watched(filepath.Join(dir, "l1/l2/new")) {
+		t.Error("a directory created below the depth limit is watched")
+	}
+}
+
+func TestRestartSurvivesRemovedRoot(t *testing.T) {
+	kept, removed := t.TempDir(), t.TempDir()
+	writeFiles(t, kept, map[string]string{"index.html": "one"})
+	writeFiles(t, removed, map[string]string{"about.html": "about"})
+	r, watchers := startTestReloader(t, Root{Path: kept}, Root{Path: removed})
+	if err := os.RemoveAll(removed); err != nil {
+		t.Fatal(err)
+	}
+
+	// The watcher fails as its root disappears.
+	watchers.last().SendError(errors.New("inotify: watched directory removed"))
+	waitFor(t, "the watcher to be recreated", func() bool {
+		return r.Stats().WatcherRestarts == 1
+	})
+	second := watchers.last()
+	if !second.Watched(kept) {
+		t.Errorf("replacement isn't watching the remaining root %s", kept)
+	}
+	waitFor(t, "the removed root's templates to be evicted", func() bool {
+		return slices.Equal(r.Names(), []string{"index"})
+	})
+
+	since := currentVersion()
+	writeFiles(t, kept, map[string]string{"index.html": "two"})
+	second.Send(filepath.Join(kept, "index.html"), fsnotify.Write)
+	published(t, since)
+	if out := execute(t, r, "index", nil); out != "two" {
+		t.Errorf("index = %q, want two", out)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// hookPath is where a Webhook is served.
+const hookPath = "/_livereload/hook"
+
+// Webhook is a ChangeSource fed over HTTP, so CI jobs or a CMS can push
+// invalidations for files the watcher can't see change. Requests must carry
+// the shared secret in the X-Livereload-Secret header and list the changed
+// files as JSON:
+//
+//	{"paths": ["templates/index.html"]}
+type Webhook struct {
+	secret string
+	events chan ChangeEvent
+	// done is closed by Close, to stop the requests waiting for the
+	// events they bring to be taken.
+	done chan struct{}
+	// sending counts those requests, which Close waits for before
+	// closing events.
+	sending   sync.WaitGroup
+	mu        sync.Mutex
+	closed    bool
+	closeOnce sync.Once
+}
+
+// NewWebhook returns a Webhook accepting requests signed with secret.
+func NewWebhook(secret string) *Webhook {
+	return &Webhook{
+		secret: secret,
+		events: make(chan ChangeEvent, 64),
+		done:   make(chan struct{}),
+	}
+}
+
+func (h *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	got := r.Header.Get("X-Livereload-Secret")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(h.secret)) != 1 {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var body struct {
+		Paths []string `json:"paths"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		http.Error(w, "Webhook closed", http.StatusServiceUnavailable)
+		return
+	}
+	h.sending.Add(1)
+	h.mu.Unlock()
+	defer h.sending.Done()
+
+	// Reloading resolves which file provides a key, so a write covers
+	// created and removed files as well.
+	for _, path := range body.Paths {
+		select {
+		case h.events <- ChangeEvent{filepath.FromSlash(path), fsnotify.Write}:
+		case <-h.done:
+			http.Error(w, "Webhook closed", http.StatusServiceUnavailable)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Webhook) Events() <-chan ChangeEvent { return h.events }
+
+// Close stops accepting requests, turning away those still waiting for
+// their changes to be taken, and closes Events once they're gone.
+func (h *Webhook) Close() error {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.done)
+	}
+	h.mu.Unlock()
+	h.sending.Wait()
+	h.closeOnce.Do(func() { close(h.events) })
+	return nil
+}
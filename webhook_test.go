@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hookRequest returns a request to h listing paths, signed with secret.
+func hookRequest(secret string, paths ...string) *http.Request {
+	body := `{"paths": ["` + strings.Join(paths, `", "`) + `"]}`
+	req := httptest.NewRequest(http.MethodPost, hookPath, strings.NewReader(body))
+	req.Header.Set("X-Livereload-Secret", secret)
+	return req
+}
+
+func TestWebhook(t *testing.T) {
+	h := NewWebhook("secret")
+	defer h.Close()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, hookRequest("wrong", "index.html"))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status %d with the wrong secret, want %d", w.Code, http.StatusForbidden)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, hookRequest("secret", "index.html", "about.html"))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusNoContent)
+	}
+	for _, want := range []string{"index.html", "about.html"} {
+		if evt := <-h.Events(); evt.Path != want {
+			t.Errorf("got a change to %s, want %s", evt.Path, want)
+		}
+	}
+}
+
+func TestWebhookCloseWithRequestsInFlight(t *testing.T) {
+	h := NewWebhook("secret")
+	// Nothing takes the events, so requests beyond the buffer wait.
+	paths := make([]string, 100)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("page%d.html", i)
+	}
+	codes := make(chan int, 3)
+	for i := 0; i < cap(codes); i++ {
+		go func() {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, hookRequest("secret", paths...))
+			codes <- w.Code
+		}()
+	}
+	waitFor(t, "the buffer to fill", func() bool { return len(h.events) == cap(h.events) })
+
+	closed := make(chan struct{})
+	go func() {
+		h.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(testTimeout):
+		t.Fatal("Close blocked on the requests in flight")
+	}
+	for i := 0; i < cap(codes); i++ {
+		if code := <-codes; code != http.StatusServiceUnavailable {
+			t.Errorf("status %d for a request cut short, want %d", code, http.StatusServiceUnavailable)
+		}
+	}
+	for range h.Events() {
+		// Events is drained and closed.
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, hookRequest("secret", "index.html"))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d once closed, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// parseWorkers returns how many pages are parsed at once.
+func (r *Reloader) parseWorkers() int {
+	if r.ParseWorkers > 0 {
+		return r.ParseWorkers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// parseEach parses the pages in sources, which maps their keys to their
+// files, on parseWorkers goroutines, and calls done with each result. Calls
+// to done are serialized, and parseEach returns once all pages are done.
+func (r *Reloader) parseEach(sources map[string]string, done func(key, path string, tmpl Template, err error)) {
+	type page struct{ key, path string }
+	// Unbuffered, so pages are handed out only as fast as workers take
+	// them.
+	pages := make(chan page)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < min(r.parseWorkers(), len(sources)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range pages {
+				tmpl, err := r.parsePage(p.path)
+				r.changes.remember(p.path)
+				mu.Lock()
+				done(p.key, p.path, tmpl, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	for key, path := range sources {
+		pages <- page{key, path}
+	}
+	close(pages)
+	wg.Wait()
+}
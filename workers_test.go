@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestParseEach(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("page%d.html", i)] = fmt.Sprintf("page %d", i)
+	}
+	r, dir, _ := newTestReloader(t, files)
+	r.ParseWorkers = 4
+	sources := map[string]string{}
+	for name := range files {
+		sources[strings.TrimSuffix(name, ".html")] = filepath.Join(dir, name)
+	}
+
+	var inFlight atomic.Int32
+	done := map[string]int{}
+	r.parseEach(sources, func(key, path string, tmpl Template, err error) {
+		if inFlight.Add(1) > 1 {
+			t.Error("done called concurrently")
+		}
+		defer inFlight.Add(-1)
+		if err != nil {
+			t.Errorf("parsing %s: %v", path, err)
+			return
+		}
+		var out strings.Builder
+		tmpl.Execute(&out, nil)
+		if want := files[filepath.Base(path)]; out.String() != want {
+			t.Errorf("%s = %q, want %q", key, out.String(), want)
+		}
+		done[key]++
+	})
+	for key := range sources {
+		if done[key] != 1 {
+			t.Errorf("%s done %d times, want once", key, done[key])
+		}
+	}
+}
+
+func TestLastWriteWins(t *testing.T) {
+	files := map[string]string{"_nav.html": "nav0"}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("page%d.html", i)] = `{{template "_nav.html"}} v0`
+	}
+	r, dir, watchers := newTestReloader(t, files)
+	r.ParseWorkers = 4
+
+	// Pages change while changes to the partial have every page reparsed
+	// by the workers.
+	var refreshes sync.WaitGroup
+	page := filepath.Join(dir, "page0.html")
+	nav := filepath.Join(dir, "_nav.html")
+	for i := 1; i <= 20; i++ {
+		writeFiles(t, dir, map[string]string{"page0.html": fmt.Sprintf(`{{template "_nav.html"}} v%d`, i)})
+		watchers.last().Send(page, fsnotify.Write)
+		if i%3 == 0 {
+			writeFiles(t, dir, map[string]string{"_nav.html": fmt.Sprintf("nav%d", i)})
+			watchers.last().Send(nav, fsnotify.Write)
+		}
+		if i%7 == 0 {
+			refreshes.Add(1)
+			go func() {
+				defer refreshes.Done()
+				r.Refresh()
+			}()
+		}
+	}
+
+	output := func(key string) string {
+		tmpl, err := r.Get(key)
+		if err != nil {
+			return err.Error()
+		}
+		var out strings.Builder
+		tmpl.Execute(&out, nil)
+		return out.String()
+	}
+	waitFor(t, "the last writes", func() bool {
+		return output("page0") == "nav18 v20" && output("page19") == "nav18 v0"
+	})
+	// Nothing parsed earlier is stored after the last writes.
+	refreshes.Wait()
+	time.Sleep(20 * testDelay)
+	if out := output("page0"); out != "nav18 v20" {
+		t.Errorf("page0 = %q, want nav18 v20", out)
+	}
+}